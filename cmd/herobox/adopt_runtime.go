@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/herozmy/herobox/internal/config"
+	"github.com/herozmy/herobox/internal/logs"
+	"github.com/herozmy/herobox/internal/procstat"
+	"github.com/herozmy/herobox/internal/service"
+)
+
+// processAdopter 通过扫描 /proc 发现 HeroBox 之外启动的核心进程，并把 PID 记录到 store，
+// 使 stop/restart 在 herobox.yaml 丢失 PID 时仍然可用。
+type processAdopter struct {
+	name        string
+	store       *config.Store
+	binaryPaths []string
+	// hint 返回当前配置路径，非空时只接管命令行引用该路径的进程。
+	hint func() string
+	// procRoot 为空时扫描 /proc。
+	procRoot string
+
+	mu        sync.Mutex
+	instances []int
+}
+
+func newProcessAdopter(name string, store *config.Store, binaryPaths []string, hint func() string) *processAdopter {
+	return &processAdopter{name: name, store: store, binaryPaths: binaryPaths, hint: hint}
+}
+
+// Scan 返回属于该服务的运行实例 PID（有配置路径时只含引用该路径的进程），并在实例数量变化时记录日志。
+func (a *processAdopter) Scan() []int {
+	hint := ""
+	if a.hint != nil {
+		hint = a.hint()
+	}
+	var pids []int
+	for _, proc := range procstat.Candidates(procstat.Find(a.procRoot, a.binaryPaths, hint), hint) {
+		pids = append(pids, proc.PID)
+	}
+	a.mu.Lock()
+	previous := a.instances
+	a.instances = pids
+	a.mu.Unlock()
+	if len(pids) > 1 && !slices.Equal(previous, pids) {
+		logs.Errorf("[service] 发现 %d 个 %s 实例在运行: %v", len(pids), a.name, pids)
+	}
+	return pids
+}
+
+// Adopt 扫描进程表；store 中的 PID 失效时接管 PID 最小的匹配实例并返回其 PID，未找到时返回 0。
+func (a *processAdopter) Adopt() int {
+	pids := a.Scan()
+	if pid := a.store.ServicePID(a.name); processRunning(pid) {
+		return pid
+	}
+	if len(pids) == 0 {
+		if a.store.ServicePID(a.name) > 0 {
+			_ = a.store.SetServicePID(a.name, 0)
+		}
+		return 0
+	}
+	pid := pids[0]
+	if err := a.store.SetServicePID(a.name, pid); err != nil {
+		logs.Errorf("[service] 记录 %s PID 失败: %v", a.name, err)
+	}
+	logs.Infof("[service] 发现已运行的 %s 进程 (PID %d)，已接管", a.name, pid)
+	return pid
+}
+
+// Terminate 结束该服务 HeroBox 之外启动的实例并等待其退出，返回被结束的 PID：
+// store 中记录的 PID 仍在运行时只结束它；否则只有唯一匹配实例时才结束，
+// 匹配到多个实例时不做处理并返回错误，由用户确认后手动结束。
+func (a *processAdopter) Terminate(ctx context.Context) ([]int, error) {
+	pids := a.Scan()
+	if stored := a.store.ServicePID(a.name); processRunning(stored) {
+		pids = []int{stored}
+	}
+	switch len(pids) {
+	case 0:
+		return nil, nil
+	case 1:
+	default:
+		return nil, fmt.Errorf("发现 %d 个 %s 实例在运行 (PID %v)，无法确定要结束哪一个，请手动结束多余的实例", len(pids), a.name, pids)
+	}
+	if _, err := stopProcess(ctx, a.name, pids[0], stopGracePeriod()); err != nil {
+		return pids, err
+	}
+	_ = a.store.SetServicePID(a.name, 0)
+	return pids, nil
+}
+
+// Annotate 在快照中列出多实例的 PID；无 PID 时补充接管的 PID。
+func (a *processAdopter) Annotate(snap *service.Snapshot) {
+	a.mu.Lock()
+	instances := slices.Clone(a.instances)
+	a.mu.Unlock()
+	if len(instances) > 1 {
+		snap.Instances = instances
+	}
+	if snap.PID == 0 && snap.Status == service.StatusRunning {
+		if pid := a.store.ServicePID(a.name); processRunning(pid) {
+			snap.PID = pid
+		}
+	}
+}
+
+// configHint 从启动参数中提取配置文件或目录（-c/-C/-d/-D 及 --config 之后的值）。
+func configHint(args []string) string {
+	for i := 0; i+1 < len(args); i++ {
+		switch args[i] {
+		case "-c", "-C", "-d", "-D", "--config", "--directory":
+			return args[i+1]
+		}
+	}
+	return ""
+}
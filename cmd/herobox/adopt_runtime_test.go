@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/herozmy/herobox/internal/config"
+)
+
+// 伪造的 PID 取接近 pid_max 的值，避免与真实进程冲突。
+const (
+	foreignPID = 4190001
+	ownPID     = 4190002
+	extraPID   = 4190003
+)
+
+func writeFakeProc(t *testing.T, root string, pid int, exe string, args ...string) {
+	t.Helper()
+	dir := filepath.Join(root, strconv.Itoa(pid))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(exe, filepath.Join(dir, "exe")); err != nil {
+		t.Fatal(err)
+	}
+	cmdline := strings.Join(append([]string{exe}, args...), "\x00") + "\x00"
+	if err := os.WriteFile(filepath.Join(dir, "cmdline"), []byte(cmdline), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestAdopter(t *testing.T, hint string) (*processAdopter, string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "sing-box")
+	if err := os.WriteFile(bin, nil, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	store, err := config.NewStore(filepath.Join(dir, "config.yaml"), filepath.Join(dir, "herobox.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	adopter := newProcessAdopter("sing-box", store, []string{bin}, func() string { return hint })
+	adopter.procRoot = filepath.Join(dir, "proc")
+	if err := os.MkdirAll(adopter.procRoot, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return adopter, adopter.procRoot, bin
+}
+
+func TestAdoptIgnoresProcessesWithOtherConfig(t *testing.T) {
+	adopter, root, bin := newTestAdopter(t, "/etc/herobox/sing-box/config.json")
+	writeFakeProc(t, root, foreignPID, bin, "run", "-c", "/srv/other/config.json")
+	if pid := adopter.Adopt(); pid != 0 {
+		t.Fatalf("Adopt = %d, want 0 for an instance using another config", pid)
+	}
+	if pids, err := adopter.Terminate(context.Background()); err != nil || len(pids) != 0 {
+		t.Fatalf("Terminate = %v, %v; want nothing terminated", pids, err)
+	}
+
+	writeFakeProc(t, root, ownPID, bin, "run", "-c", "/etc/herobox/sing-box/config.json")
+	if pid := adopter.Adopt(); pid != ownPID {
+		t.Fatalf("Adopt = %d, want %d", pid, ownPID)
+	}
+	if got := adopter.store.ServicePID("sing-box"); got != ownPID {
+		t.Fatalf("stored PID = %d, want %d", got, ownPID)
+	}
+}
+
+func TestTerminateRefusesMultipleInstances(t *testing.T) {
+	adopter, root, bin := newTestAdopter(t, "/etc/herobox/sing-box/config.json")
+	writeFakeProc(t, root, ownPID, bin, "run", "-c", "/etc/herobox/sing-box/config.json")
+	writeFakeProc(t, root, extraPID, bin, "run", "-D", "/etc/herobox/sing-box")
+	pids, err := adopter.Terminate(context.Background())
+	if err == nil || len(pids) != 0 {
+		t.Fatalf("Terminate = %v, %v; want an error without terminating", pids, err)
+	}
+	for _, pid := range []int{ownPID, extraPID} {
+		if !strings.Contains(err.Error(), strconv.Itoa(pid)) {
+			t.Fatalf("error %q does not list PID %d", err, pid)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/herozmy/herobox/internal/config"
+	"github.com/herozmy/herobox/internal/service"
+	"gopkg.in/yaml.v3"
+)
+
+// configCheckDisabled 允许通过 HEROBOX_SKIP_CONFIG_CHECK=true 跳过启动前的配置检查。
+func configCheckDisabled() bool {
+	return strings.EqualFold(getenv("HEROBOX_SKIP_CONFIG_CHECK", ""), "true")
+}
+
+// commandCheck 返回运行核心自带检查命令的 Check，buildArgs 根据启动参数生成检查参数。
+func commandCheck(buildArgs func(args []string) []string) func(ctx context.Context, spec service.ServiceSpec) error {
+	return func(ctx context.Context, spec service.ServiceSpec) error {
+		if configCheckDisabled() || spec.Args == nil {
+			return nil
+		}
+		binary, err := firstExistingBinary(spec.BinaryPaths)
+		if err != nil {
+			return err
+		}
+		args := buildArgs(spec.Args())
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		output, err := exec.CommandContext(ctx, binary, args...).CombinedOutput()
+		if err == nil {
+			return nil
+		}
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return fmt.Errorf("运行 %s 配置检查失败: %w", spec.Name, err)
+		}
+		return &service.CheckError{
+			Service: spec.Name,
+			Command: strings.Join(append([]string{binary}, args...), " "),
+			Output:  strings.TrimSpace(string(output)),
+		}
+	}
+}
+
+// unitCommandCheck 用于 systemd 管理的服务：按 unit 的 ExecStart 检查实际加载的配置，
+// 无法读取 ExecStart（例如 unit 尚未安装）时退回 HeroBox 解析的启动参数。
+func unitCommandCheck(buildArgs func(args []string) []string) func(ctx context.Context, spec service.ServiceSpec) error {
+	check := commandCheck(buildArgs)
+	return func(ctx context.Context, spec service.ServiceSpec) error {
+		if configCheckDisabled() {
+			return nil
+		}
+		if binary, args, ok := unitExecStart(ctx, spec.Unit); ok {
+			spec.BinaryPaths = append([]string{binary}, spec.BinaryPaths...)
+			spec.Args = func() []string { return args }
+		}
+		return check(ctx, spec)
+	}
+}
+
+// unitExecStart 通过 systemctl show -p ExecStart 读取 unit 的二进制路径与参数（不含 argv[0]）。
+func unitExecStart(ctx context.Context, unit string) (string, []string, bool) {
+	if unit == "" {
+		return "", nil, false
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, "systemctl", "show", unit, "-p", "ExecStart", "--value").Output()
+	if err != nil {
+		return "", nil, false
+	}
+	return parseExecStart(string(output))
+}
+
+// parseExecStart 解析 systemctl show 的 ExecStart 值，例如
+// "{ path=/usr/local/bin/sing-box ; argv[]=/usr/local/bin/sing-box run -c /etc/sing-box/config.json ; ... }"。
+// 多条 ExecStart 时取第一条；argv 按空白切分，与 systemctl 的展示一致。
+func parseExecStart(value string) (string, []string, bool) {
+	field := func(name string) string {
+		_, rest, ok := strings.Cut(value, name+"=")
+		if !ok {
+			return ""
+		}
+		if end := strings.Index(rest, " ;"); end >= 0 {
+			rest = rest[:end]
+		}
+		return strings.TrimSpace(rest)
+	}
+	path := field("path")
+	argv := strings.Fields(field("argv[]"))
+	if path == "" || len(argv) == 0 {
+		return "", nil, false
+	}
+	return path, argv[1:], true
+}
+
+// singBoxCheckArgs 将 run 子命令替换为 check，保留 -c/-C/-D 等参数。
+func singBoxCheckArgs(args []string) []string {
+	out := append([]string(nil), args...)
+	if len(out) > 0 && out[0] == "run" {
+		out[0] = "check"
+		return out
+	}
+	return append([]string{"check"}, out...)
+}
+
+// mihomoCheckArgs 在启动参数前加上 -t，仅测试配置后退出。
+func mihomoCheckArgs(args []string) []string {
+	return append([]string{"-t"}, args...)
+}
+
+// mosdnsCheck 解析 mosdns 主配置及其 include 的全部文件，检查 YAML 语法、插件 type 与 tag 重复。
+func mosdnsCheck(store *config.Store) func(ctx context.Context, spec service.ServiceSpec) error {
+	defaultDataDir := getenv("MOSDNS_DATA_DIR", "")
+	return func(ctx context.Context, spec service.ServiceSpec) error {
+		if configCheckDisabled() {
+			return nil
+		}
+		cfg := store.GetConfigPath()
+		workDir := resolveMosdnsDataDir(defaultDataDir, cfg)
+		var report bytes.Buffer
+		if err := checkMosdnsConfig(cfg, workDir, &report); err != nil {
+			fmt.Fprintf(&report, "%v\n", err)
+			return &service.CheckError{
+				Service: spec.Name,
+				Command: "yaml parse " + cfg,
+				Output:  strings.TrimSpace(report.String()),
+			}
+		}
+		return nil
+	}
+}
+
+// mosdnsConfigFile 是 mosdns 配置中与检查、探测相关的字段。
+type mosdnsConfigFile struct {
+	Include []string `yaml:"include"`
+	Plugins []struct {
+		Tag  string    `yaml:"tag"`
+		Type string    `yaml:"type"`
+		Args yaml.Node `yaml:"args"`
+	} `yaml:"plugins"`
+}
+
+// checkMosdnsConfig 按 mosdns 的规则（相对路径基于工作目录 -d）递归解析 include，report 记录已检查的文件。
+func checkMosdnsConfig(path, workDir string, report *bytes.Buffer) error {
+	tags := make(map[string]string)
+	return walkMosdnsConfig(path, workDir, func(abs string, doc mosdnsConfigFile) error {
+		fmt.Fprintf(report, "ok %s (%d plugins)\n", abs, len(doc.Plugins))
+		for i, plugin := range doc.Plugins {
+			if strings.TrimSpace(plugin.Type) == "" {
+				return fmt.Errorf("%s: 第 %d 个插件 (tag %q) 缺少 type", abs, i+1, plugin.Tag)
+			}
+			if plugin.Tag == "" {
+				continue
+			}
+			if prev, ok := tags[plugin.Tag]; ok {
+				return fmt.Errorf("%s: 插件 tag %q 与 %s 重复", abs, plugin.Tag, prev)
+			}
+			tags[plugin.Tag] = abs
+		}
+		return nil
+	})
+}
+
+// walkMosdnsConfig 解析主配置并深度优先访问其 include 的文件，每个文件只访问一次。
+func walkMosdnsConfig(path, workDir string, visit func(abs string, doc mosdnsConfigFile) error) error {
+	visited := make(map[string]bool)
+	var walk func(file string, depth int) error
+	walk = func(file string, depth int) error {
+		if depth > 8 {
+			return fmt.Errorf("%s: include 层级过深", file)
+		}
+		abs := file
+		if !filepath.IsAbs(abs) {
+			abs = filepath.Join(workDir, file)
+		}
+		abs = filepath.Clean(abs)
+		if visited[abs] {
+			return nil
+		}
+		visited[abs] = true
+		data, err := os.ReadFile(abs)
+		if err != nil {
+			return fmt.Errorf("读取 %s 失败: %w", abs, err)
+		}
+		var doc mosdnsConfigFile
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("%s: %w", abs, err)
+		}
+		if err := visit(abs, doc); err != nil {
+			return err
+		}
+		for _, inc := range doc.Include {
+			if strings.TrimSpace(inc) == "" {
+				continue
+			}
+			if err := walk(inc, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(path, 0)
+}
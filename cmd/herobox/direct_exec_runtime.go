@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/herozmy/herobox/internal/config"
+	"github.com/herozmy/herobox/internal/logs"
+	"github.com/herozmy/herobox/internal/service"
+)
+
+// directExecEnabled 判断是否需要直接执行核心二进制：未找到 systemctl 或显式设置 HEROBOX_DIRECT_EXEC=true。
+func directExecEnabled() bool {
+	if strings.EqualFold(getenv("HEROBOX_DIRECT_EXEC", ""), "true") {
+		return true
+	}
+	_, err := exec.LookPath("systemctl")
+	return err != nil
+}
+
+// newExecHooks 生成通用的直接执行驱动，buildArgs 在每次启动时计算命令行参数，
+// 进程由 processSupervisor 看护，PID 记录在 store 中。
+func newExecHooks(store *config.Store, name string, binaryPaths []string, buildArgs func() []string) service.ServiceHooks {
+	supervisor := newProcessSupervisor(name, store, func(out io.Writer) (*exec.Cmd, error) {
+		binary, err := firstExistingBinary(binaryPaths)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return startProcess(binary, out, buildArgs()...)
+	})
+	adopter := newProcessAdopter(name, store, binaryPaths, func() string { return configHint(buildArgs()) })
+	return withAutostart(store, service.ServiceHooks{
+		Start: func(ctx context.Context, spec service.ServiceSpec) error {
+			if !supervisor.Running() {
+				if pid := adopter.Adopt(); pid > 0 {
+					return fmt.Errorf("%s 已在运行 (PID %d)", spec.Name, pid)
+				}
+			}
+			return supervisor.Start()
+		},
+		Stop: func(ctx context.Context, spec service.ServiceSpec) error {
+			return stopSupervised(ctx, supervisor, adopter, spec.Name)
+		},
+		Restart: func(ctx context.Context, spec service.ServiceSpec) error {
+			return restartSupervised(ctx, supervisor, adopter)
+		},
+		Status: func(ctx context.Context, spec service.ServiceSpec) (service.Status, error) {
+			pid := adopter.Adopt()
+			if supervisor.Running() || pid > 0 {
+				return service.StatusRunning, nil
+			}
+			return service.StatusStopped, nil
+		},
+		Annotate: func(spec service.ServiceSpec, snap *service.Snapshot) {
+			supervisor.Annotate(snap)
+			adopter.Annotate(snap)
+		},
+	})
+}
+
+// stopSupervised 停止看护中的进程并等待其退出；未由 HeroBox 拉起时结束接管的实例。
+func stopSupervised(ctx context.Context, supervisor *processSupervisor, adopter *processAdopter, name string) error {
+	if supervisor.Running() {
+		if err := supervisor.Stop(ctx); err != nil {
+			return err
+		}
+		return adopter.store.SetServicePID(name, 0)
+	}
+	if supervisor.Disarm() {
+		return adopter.store.SetServicePID(name, 0)
+	}
+	pids, err := adopter.Terminate(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pids) == 0 {
+		return fmt.Errorf("未找到运行中的 %s 进程", name)
+	}
+	logs.Infof("[service] 已结束 HeroBox 之外启动的 %s 进程: %v", name, pids)
+	return nil
+}
+
+// restartSupervised 确认旧进程（含 HeroBox 之外启动的实例）退出后再拉起新进程，存在多个实例时不重启。
+func restartSupervised(ctx context.Context, supervisor *processSupervisor, adopter *processAdopter) error {
+	if !supervisor.Running() {
+		if _, err := adopter.Terminate(ctx); err != nil {
+			return err
+		}
+	}
+	return supervisor.Restart(ctx)
+}
+
+// withAutostart 为直接执行的服务补充开机自启控制，标记持久化在 herobox.yaml 中。
+func withAutostart(store *config.Store, hooks service.ServiceHooks) service.ServiceHooks {
+	hooks.SetEnabled = func(ctx context.Context, spec service.ServiceSpec, enabled bool) error {
+		return store.SetAutostart(spec.Name, enabled)
+	}
+	hooks.Enabled = func(ctx context.Context, spec service.ServiceSpec) (bool, error) {
+		return store.Autostart(spec.Name), nil
+	}
+	return hooks
+}
+
+// autostartServices 在 HeroBox 启动时拉起标记为自启且尚未运行的直接执行服务。
+func autostartServices(mgr *service.Manager, store *config.Store, names ...string) {
+	for _, name := range names {
+		if !store.Autostart(name) {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(service.WithCause(context.Background(), service.CauseBoot), 15*time.Second)
+		snap, err := mgr.Status(ctx, name)
+		if err == nil && !snap.Status.Active() && snap.Status != service.StatusMissing {
+			logs.Infof("[service] %s 已标记开机自启，正在启动", name)
+			if err := mgr.Start(ctx, name); err != nil {
+				logs.Errorf("[service] 自启 %s 失败: %v", name, err)
+			}
+		}
+		cancel()
+	}
+}
+
+// singBoxArgs 返回 sing-box 启动参数，SING_BOX_ARGS 可整体覆盖。
+func singBoxArgs() []string {
+	if args := strings.Fields(getenv("SING_BOX_ARGS", "")); len(args) > 0 {
+		return args
+	}
+	cfg := getenv("SING_BOX_CONFIG_PATH", "/etc/herobox/sing-box/config.json")
+	dataDir := getenv("SING_BOX_DATA_DIR", filepath.Dir(cfg))
+	return []string{"run", "-c", cfg, "-D", dataDir}
+}
+
+// mihomoArgs 返回 mihomo 启动参数，MIHOMO_ARGS 可整体覆盖。
+func mihomoArgs() []string {
+	if args := strings.Fields(getenv("MIHOMO_ARGS", "")); len(args) > 0 {
+		return args
+	}
+	return []string{"-d", getenv("MIHOMO_DATA_DIR", "/etc/herobox/mihomo")}
+}
+
+// processRunning 通过进程表判断 PID 是否仍存活；僵尸进程视为已退出。
+func processRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	data, err := os.ReadFile(filepath.Join("/proc", fmt.Sprint(pid), "stat"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			if _, statErr := os.Stat("/proc/self"); statErr == nil {
+				return false
+			}
+		}
+		// 无 /proc 的平台退回信号探测。
+		return processAlive(pid)
+	}
+	return processStateFromStat(data) != 'Z'
+}
+
+// processStateFromStat 解析 /proc/<pid>/stat 中紧跟进程名之后的状态字段。
+func processStateFromStat(data []byte) byte {
+	idx := bytes.LastIndexByte(data, ')')
+	if idx < 0 || idx+2 >= len(data) {
+		return 0
+	}
+	return data[idx+2]
+}
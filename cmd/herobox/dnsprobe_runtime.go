@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/herozmy/herobox/internal/config"
+	"github.com/herozmy/herobox/internal/dnsprobe"
+	"github.com/herozmy/herobox/internal/service"
+	"gopkg.in/yaml.v3"
+)
+
+// dnsProbeEnabled 允许通过 HEROBOX_DNS_PROBE=false 关闭 mosdns 的 DNS 健康探测。
+func dnsProbeEnabled() bool {
+	return !strings.EqualFold(getenv("HEROBOX_DNS_PROBE", ""), "false")
+}
+
+// dnsTarget 为一个待探测的 DNS 监听地址。
+type dnsTarget struct {
+	network string
+	address string
+}
+
+// dnsHealth 向 mosdns 的 DNS 监听地址发送真实查询，记录延迟与 rcode；
+// 结果在 HEROBOX_DNS_PROBE_INTERVAL 内复用，避免状态轮询时重复查询。
+type dnsHealth struct {
+	store          *config.Store
+	defaultDataDir string
+
+	mu   sync.Mutex
+	last *service.HealthReport
+}
+
+func newDNSHealth(store *config.Store) *dnsHealth {
+	return &dnsHealth{store: store, defaultDataDir: getenv("MOSDNS_DATA_DIR", "")}
+}
+
+// Check 满足 ServiceSpec.Health：任一查询失败、rcode 异常或延迟超过阈值时报告不健康。
+func (h *dnsHealth) Check(ctx context.Context, spec service.ServiceSpec) *service.HealthReport {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.last == nil || time.Since(h.last.CheckedAt) >= envDuration("HEROBOX_DNS_PROBE_INTERVAL", 5*time.Second) {
+		h.last = h.probe(ctx)
+	}
+	report := *h.last
+	return &report
+}
+
+func (h *dnsHealth) probe(ctx context.Context) *service.HealthReport {
+	name := getenv("HEROBOX_DNS_PROBE_NAME", "www.baidu.com")
+	threshold := envDuration("HEROBOX_DNS_PROBE_THRESHOLD", time.Second)
+	timeout := envDuration("HEROBOX_DNS_PROBE_TIMEOUT", 2*time.Second)
+	targets := h.targets()
+	results := make([]dnsprobe.Result, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			queryCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			results[i] = dnsprobe.Query(queryCtx, target.network, target.address, name, dnsprobe.TypeA)
+		}()
+	}
+	wg.Wait()
+
+	report := &service.HealthReport{
+		CheckedAt:   time.Now(),
+		Healthy:     true,
+		ThresholdMS: float64(threshold.Milliseconds()),
+		Probes:      results,
+	}
+	var problems []string
+	for _, result := range results {
+		where := result.Network + " " + result.Address
+		switch {
+		case result.Error != "":
+			problems = append(problems, fmt.Sprintf("%s 查询失败: %s", where, result.Error))
+		case !result.OK():
+			problems = append(problems, fmt.Sprintf("%s 返回 %s", where, result.Rcode))
+		case result.LatencyMS > report.ThresholdMS:
+			problems = append(problems, fmt.Sprintf("%s 耗时 %.0fms 超过阈值 %s", where, result.LatencyMS, threshold))
+		}
+	}
+	if len(problems) > 0 {
+		report.Healthy = false
+		report.Reason = strings.Join(problems, "; ")
+	}
+	return report
+}
+
+// targets 优先使用 mosdns 配置中 udp_server/tcp_server 的 listen 地址，
+// 未找到时退回设置中的 listenAddress7777/8888，并分别以 UDP 与 TCP 探测。
+func (h *dnsHealth) targets() []dnsTarget {
+	cfg := h.store.GetConfigPath()
+	workDir := resolveMosdnsDataDir(h.defaultDataDir, cfg)
+	var targets []dnsTarget
+	seen := make(map[dnsTarget]bool)
+	add := func(target dnsTarget) {
+		if !seen[target] {
+			seen[target] = true
+			targets = append(targets, target)
+		}
+	}
+	_ = walkMosdnsConfig(cfg, workDir, func(abs string, doc mosdnsConfigFile) error {
+		for _, plugin := range doc.Plugins {
+			var network string
+			switch plugin.Type {
+			case "udp_server":
+				network = "udp"
+			case "tcp_server":
+				network = "tcp"
+			default:
+				continue
+			}
+			if plugin.Args.Kind != yaml.MappingNode {
+				continue
+			}
+			var args struct {
+				Listen string `yaml:"listen"`
+			}
+			if err := plugin.Args.Decode(&args); err != nil || strings.TrimSpace(args.Listen) == "" {
+				continue
+			}
+			add(dnsTarget{network: network, address: probeAddress(args.Listen)})
+		}
+		return nil
+	})
+	if len(targets) > 0 {
+		return targets
+	}
+	for _, listen := range []string{resolveListenAddress7777(h.store), resolveListenAddress8888(h.store)} {
+		address := probeAddress(listen)
+		add(dnsTarget{network: "udp", address: address})
+		add(dnsTarget{network: "tcp", address: address})
+	}
+	return targets
+}
+
+// probeAddress 把监听地址转换为可拨号的本机地址：省略或通配的主机替换为 127.0.0.1。
+func probeAddress(listen string) string {
+	listen = strings.TrimSpace(listen)
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return listen
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port)
+}
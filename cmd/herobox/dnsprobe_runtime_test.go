@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/herozmy/herobox/internal/config"
+)
+
+// dnsReply 复制查询的 ID 与问题，置 QR/RD/RA 标志、NOERROR 与一条应答计数。
+func dnsReply(query []byte) []byte {
+	resp := append([]byte(nil), query...)
+	binary.BigEndian.PutUint16(resp[2:], 0x8180)
+	binary.BigEndian.PutUint16(resp[6:], 1)
+	return resp
+}
+
+// startDNSResponders 在 127.0.0.1 上启动 UDP 与 TCP 应答器，delay 为每次应答前的等待时间。
+func startDNSResponders(t *testing.T, delay time.Duration) (udpAddr, tcpPort string) {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close(); ln.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			time.Sleep(delay)
+			_, _ = pc.WriteTo(dnsReply(buf[:n]), addr)
+		}
+	}()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				var size [2]byte
+				if _, err := io.ReadFull(conn, size[:]); err != nil {
+					return
+				}
+				query := make([]byte, binary.BigEndian.Uint16(size[:]))
+				if _, err := io.ReadFull(conn, query); err != nil {
+					return
+				}
+				time.Sleep(delay)
+				resp := dnsReply(query)
+				_, _ = conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(resp))), resp...))
+			}()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	return pc.LocalAddr().String(), port
+}
+
+func TestDNSHealthThreshold(t *testing.T) {
+	t.Setenv("HEROBOX_DNS_PROBE_THRESHOLD", "100ms")
+	t.Setenv("HEROBOX_DNS_PROBE_TIMEOUT", "2s")
+	cases := []struct {
+		name    string
+		delay   time.Duration
+		healthy bool
+	}{
+		{"fast", 0, true},
+		{"slow", 200 * time.Millisecond, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			udpAddr, tcpPort := startDNSResponders(t, tc.delay)
+			dir := t.TempDir()
+			cfg := filepath.Join(dir, "config.yaml")
+			// tcp_server 只写端口，探测时应补全为 127.0.0.1。
+			content := fmt.Sprintf("plugins:\n  - tag: udp\n    type: udp_server\n    args:\n      listen: %q\n  - tag: tcp\n    type: tcp_server\n    args:\n      listen: \":%s\"\n", udpAddr, tcpPort)
+			if err := os.WriteFile(cfg, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			store, err := config.NewStore(cfg, filepath.Join(dir, "herobox.yaml"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			report := newDNSHealth(store).probe(context.Background())
+			if len(report.Probes) != 2 || report.Probes[0].Network != "udp" || report.Probes[1].Address != "127.0.0.1:"+tcpPort {
+				t.Fatalf("probes = %+v", report.Probes)
+			}
+			if report.Healthy != tc.healthy {
+				t.Fatalf("healthy = %v, want %v (reason %q)", report.Healthy, tc.healthy, report.Reason)
+			}
+			if !tc.healthy && !strings.Contains(report.Reason, "超过阈值") {
+				t.Fatalf("reason = %q, want threshold exceeded", report.Reason)
+			}
+			for _, probe := range report.Probes {
+				if probe.Rcode != "NOERROR" || probe.LatencyMS < float64(tc.delay.Milliseconds()) {
+					t.Fatalf("probe = %+v", probe)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/herozmy/herobox/internal/service"
+)
+
+// serviceEvents 为全局服务事件日志，看护协程据此记录崩溃与自动重启。
+var serviceEvents *service.EventLog
+
+// newServiceEvents 在 HeroBox 数据目录下保存事件，每个服务保留 HEROBOX_EVENT_LIMIT（默认 500）条。
+func newServiceEvents() *service.EventLog {
+	return service.NewEventLog(filepath.Join(heroboxDataDir(), "events"), envInt("HEROBOX_EVENT_LIMIT", 500))
+}
+
+// serveServiceEvents 处理 GET /api/services/{name}/events?since=&until=&limit=，按时间顺序返回事件。
+func serveServiceEvents(w http.ResponseWriter, r *http.Request, mgr *service.Manager, name string) {
+	spec, err := mgr.Spec(name)
+	if err != nil {
+		respondErr(w, err)
+		return
+	}
+	q := r.URL.Query()
+	now := time.Now()
+	since, err := parseEventTime(q.Get("since"), now)
+	if err != nil {
+		respondErr(w, err)
+		return
+	}
+	until, err := parseEventTime(q.Get("until"), now)
+	if err != nil {
+		respondErr(w, err)
+		return
+	}
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	events := mgr.Events.Query(spec.Name, since, until, limit)
+	if events == nil {
+		events = []service.Event{}
+	}
+	respondJSON(w, map[string]any{
+		"service": spec.Name,
+		"events":  events,
+	})
+}
+
+// parseEventTime 支持 RFC3339、"2006-01-02 15:04:05"（本地时间）与相对时长（如 12h 表示 12 小时前），空串表示不限。
+func parseEventTime(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if d, err := time.ParseDuration(strings.TrimPrefix(value, "-")); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("无法解析时间 %q，支持 RFC3339、2006-01-02 15:04:05 或 12h 等相对时长", value)
+}
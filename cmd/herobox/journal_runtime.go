@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/herozmy/herobox/internal/logs"
+	"github.com/herozmy/herobox/internal/service"
+)
+
+// journalQuery 对应 /api/services/{name}/journal 支持的过滤条件。
+type journalQuery struct {
+	Since    string
+	Until    string
+	Limit    int
+	Priority string
+}
+
+// serveServiceJournal 处理 GET /api/services/{name}/journal?since=&until=&limit=&priority=。
+func serveServiceJournal(w http.ResponseWriter, r *http.Request, mgr *service.Manager, name string) {
+	spec, err := mgr.Spec(name)
+	if err != nil {
+		respondErr(w, err)
+		return
+	}
+	if spec.Unit == "" {
+		respondErr(w, fmt.Errorf("%s 未配置 systemd unit", spec.Name))
+		return
+	}
+	q := r.URL.Query()
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	query := journalQuery{
+		Since:    strings.TrimSpace(q.Get("since")),
+		Until:    strings.TrimSpace(q.Get("until")),
+		Limit:    limit,
+		Priority: strings.TrimSpace(q.Get("priority")),
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	entries, err := readJournal(ctx, spec.Unit, query)
+	if err != nil {
+		respondErr(w, err)
+		return
+	}
+	respondJSON(w, map[string]any{
+		"entries": entries,
+		"unit":    spec.Unit,
+	})
+}
+
+// readJournal 执行 journalctl -u <unit> -o json 并转换为 logs.Entry。
+func readJournal(ctx context.Context, unit string, query journalQuery) ([]logs.Entry, error) {
+	if query.Limit <= 0 || query.Limit > 5000 {
+		query.Limit = 400
+	}
+	args := []string{"-u", unit, "-o", "json", "--no-pager", "-n", strconv.Itoa(query.Limit)}
+	// 使用 --opt=value 形式，避免参数值被解析为额外选项。
+	if query.Since != "" {
+		args = append(args, "--since="+query.Since)
+	}
+	if query.Until != "" {
+		args = append(args, "--until="+query.Until)
+	}
+	if query.Priority != "" {
+		args = append(args, "--priority="+query.Priority)
+	}
+	cmd := exec.CommandContext(ctx, "journalctl", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil, errors.New("未找到 journalctl，当前系统可能未使用 systemd，请改用 /output 查看输出")
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("journalctl 执行失败: %s", msg)
+		}
+		return nil, fmt.Errorf("journalctl 执行失败: %w", err)
+	}
+	entries := make([]logs.Entry, 0, query.Limit)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 4<<20)
+	for scanner.Scan() {
+		if entry, ok := parseJournalLine(scanner.Bytes()); ok {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func parseJournalLine(line []byte) (logs.Entry, bool) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return logs.Entry{}, false
+	}
+	var record map[string]json.RawMessage
+	if err := json.Unmarshal(line, &record); err != nil {
+		return logs.Entry{}, false
+	}
+	entry := logs.Entry{
+		Timestamp: time.Now(),
+		Level:     journalLevel(journalString(record["PRIORITY"])),
+		Message:   journalString(record["MESSAGE"]),
+	}
+	if usec, err := strconv.ParseInt(journalString(record["__REALTIME_TIMESTAMP"]), 10, 64); err == nil {
+		entry.Timestamp = time.UnixMicro(usec)
+	}
+	return entry, true
+}
+
+// journalString 解析 journald JSON 字段：普通字符串，或非 UTF-8 内容时的字节数组。
+func journalString(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	var ints []int
+	if err := json.Unmarshal(raw, &ints); err == nil {
+		b := make([]byte, len(ints))
+		for i, v := range ints {
+			b[i] = byte(v)
+		}
+		return string(b)
+	}
+	return ""
+}
+
+func journalLevel(priority string) string {
+	switch priority {
+	case "0", "1", "2", "3":
+		return "error"
+	case "4":
+		return "warn"
+	case "7":
+		return "debug"
+	default:
+		return "info"
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeJournalctl 在临时目录写入 journalctl 脚本：记录参数到 args 文件并输出固定的 JSON 记录。
+func fakeJournalctl(t *testing.T, output string) (dir, argsFile string) {
+	t.Helper()
+	dir = t.TempDir()
+	argsFile = filepath.Join(dir, "args")
+	script := "#!/bin/sh\nprintf '%s\\n' \"$@\" > " + argsFile + "\ncat <<'EOF'\n" + output + "\nEOF\n"
+	if err := os.WriteFile(filepath.Join(dir, "journalctl"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return dir, argsFile
+}
+
+func TestReadJournal(t *testing.T) {
+	const records = `{"__REALTIME_TIMESTAMP":"1700000000000000","PRIORITY":"3","MESSAGE":"boom"}
+not json
+{"__REALTIME_TIMESTAMP":"1700000001000000","PRIORITY":"6","MESSAGE":[104,105]}`
+	cases := []struct {
+		name     string
+		query    journalQuery
+		wantArgs []string
+		noArgs   []string
+	}{
+		{
+			name:     "defaults",
+			query:    journalQuery{},
+			wantArgs: []string{"-u", "mosdns.service", "-o", "json", "--no-pager", "-n", "400"},
+			noArgs:   []string{"--since", "--until", "--priority"},
+		},
+		{
+			name:     "since and lines",
+			query:    journalQuery{Since: "-1h", Limit: 50},
+			wantArgs: []string{"-n", "50", "--since=-1h"},
+			noArgs:   []string{"--until"},
+		},
+		{
+			name:     "until and priority",
+			query:    journalQuery{Since: "2024-01-01 00:00:00", Until: "today", Priority: "err", Limit: 9000},
+			wantArgs: []string{"-n", "400", "--since=2024-01-01 00:00:00", "--until=today", "--priority=err"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, argsFile := fakeJournalctl(t, records)
+			t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			entries, err := readJournal(ctx, "mosdns.service", tc.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(argsFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			args := strings.Split(strings.TrimSpace(string(data)), "\n")
+			joined := "\n" + strings.Join(args, "\n") + "\n"
+			if !strings.Contains(joined, "\n"+strings.Join(tc.wantArgs, "\n")+"\n") {
+				t.Fatalf("args %q do not contain %q", args, tc.wantArgs)
+			}
+			for _, absent := range tc.noArgs {
+				for _, arg := range args {
+					if strings.HasPrefix(arg, absent) {
+						t.Fatalf("unexpected argument %q in %q", arg, args)
+					}
+				}
+			}
+			if len(entries) != 2 {
+				t.Fatalf("got %d entries, want 2", len(entries))
+			}
+			if entries[0].Level != "error" || entries[0].Message != "boom" || !entries[0].Timestamp.Equal(time.UnixMicro(1700000000000000)) {
+				t.Fatalf("unexpected first entry %+v", entries[0])
+			}
+			if entries[1].Level != "info" || entries[1].Message != "hi" {
+				t.Fatalf("unexpected second entry %+v", entries[1])
+			}
+		})
+	}
+
+	t.Run("journalctl missing", func(t *testing.T) {
+		t.Setenv("PATH", t.TempDir())
+		_, err := readJournal(context.Background(), "mosdns.service", journalQuery{})
+		if err == nil || !strings.Contains(err.Error(), "未找到 journalctl") {
+			t.Fatalf("err = %v, want missing journalctl error", err)
+		}
+	})
+}
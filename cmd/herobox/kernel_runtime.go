@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/herozmy/herobox/internal/logs"
+	"github.com/herozmy/herobox/internal/mosdns"
+	"github.com/herozmy/herobox/internal/service"
+)
+
+// registerKernelRoutes 为非 mosdns 核心注册 latest/update/releases/install 接口，例如 /api/sing-box/kernel。
+func registerKernelRoutes(mux *http.ServeMux, prefix string, updater *mosdns.Updater) {
+	mux.HandleFunc(prefix+"/latest", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		rel, err := updater.Client.LatestRelease(ctx)
+		if err != nil {
+			respondErr(w, err)
+			return
+		}
+		respondJSON(w, rel)
+	})
+
+	mux.HandleFunc(prefix+"/update", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Minute)
+		defer cancel()
+		rel, path, err := updater.UpdateLatest(ctx)
+		if err != nil {
+			respondErr(w, err)
+			return
+		}
+		respondJSON(w, map[string]any{
+			"release": rel,
+			"binary":  path,
+		})
+	})
+
+	registerKernelReleaseRoutes(mux, prefix, updater, nil)
+}
+
+// kernelReleaseEntry 为发行版列表中的一项，Asset 为当前平台会下载的资产名。
+type kernelReleaseEntry struct {
+	*mosdns.Release
+	Asset string `json:"asset,omitempty"`
+}
+
+// registerKernelReleaseRoutes 注册 releases（分页列出发行版）与 install（安装指定 tag）接口，
+// installed 在安装成功后调用，例如刷新记录的 mosdns 版本。
+func registerKernelReleaseRoutes(mux *http.ServeMux, prefix string, updater *mosdns.Updater, installed func()) {
+	mux.HandleFunc(prefix+"/releases", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w)
+			return
+		}
+		q := r.URL.Query()
+		page, _ := strconv.Atoi(q.Get("page"))
+		if page < 1 {
+			page = 1
+		}
+		perPage, _ := strconv.Atoi(q.Get("perPage"))
+		if perPage < 1 || perPage > 100 {
+			perPage = 10
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		releases, hasMore, err := updater.Client.ListReleases(ctx, page, perPage)
+		if err != nil {
+			respondErr(w, err)
+			return
+		}
+		entries := make([]kernelReleaseEntry, 0, len(releases))
+		for i := range releases {
+			entry := kernelReleaseEntry{Release: &releases[i]}
+			if asset, err := updater.PickAsset(releases[i].Assets); err == nil {
+				entry.Asset = asset.Name
+			}
+			entries = append(entries, entry)
+		}
+		respondJSON(w, map[string]any{
+			"releases": entries,
+			"page":     page,
+			"perPage":  perPage,
+			"hasMore":  hasMore,
+		})
+	})
+
+	mux.HandleFunc(prefix+"/install", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w)
+			return
+		}
+		tag := strings.TrimSpace(r.URL.Query().Get("tag"))
+		if tag == "" {
+			respondErr(w, errors.New("缺少 tag 参数，例如 ?tag=v5.3.3"))
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Minute)
+		defer cancel()
+		rel, path, err := updater.InstallTag(ctx, tag)
+		if err != nil {
+			respondErr(w, err)
+			return
+		}
+		if installed != nil {
+			installed()
+		}
+		respondJSON(w, map[string]any{
+			"release": rel,
+			"binary":  path,
+		})
+	})
+}
+
+// registerKernelBackupRoutes 注册 backups（列出替换下来的旧内核）与 rollback（切换回指定版本）接口，
+// rollback 带 restart=true 时在服务运行中重启服务。
+func registerKernelBackupRoutes(mux *http.ServeMux, prefix string, updater *mosdns.Updater, mgr *service.Manager, name string, installed func()) {
+	mux.HandleFunc(prefix+"/backups", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w)
+			return
+		}
+		backups, current, err := updater.Backups.List(updater.Target())
+		if err != nil {
+			respondErr(w, err)
+			return
+		}
+		if backups == nil {
+			backups = []mosdns.Backup{}
+		}
+		respondJSON(w, map[string]any{
+			"binary":  updater.Target(),
+			"current": current,
+			"backups": backups,
+		})
+	})
+
+	mux.HandleFunc(prefix+"/rollback", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w)
+			return
+		}
+		q := r.URL.Query()
+		version := strings.TrimSpace(q.Get("version"))
+		if version == "" {
+			respondErr(w, errors.New("缺少 version 参数"))
+			return
+		}
+		restored, err := updater.Backups.Restore(r.Context(), updater.Target(), version)
+		if err != nil {
+			respondErr(w, err)
+			return
+		}
+		logs.InfofContext(r.Context(), "[%s] 内核已切换回 %s", name, restored.Version)
+		if installed != nil {
+			installed()
+		}
+		result := map[string]any{
+			"binary":   updater.Target(),
+			"restored": restored,
+		}
+		if restart, _ := strconv.ParseBool(q.Get("restart")); restart {
+			restartIfActive(r.Context(), mgr, name, result)
+		}
+		respondJSON(w, result)
+	})
+}
+
+// primaryBinary 返回候选列表中首个路径，用作内核更新的安装目标。
+func primaryBinary(paths []string) string {
+	if len(paths) == 0 {
+		return ""
+	}
+	return paths[0]
+}
+
+// serviceBinaryTarget 返回按当前注册的服务定义计算安装路径的函数，
+// 通过 /api/service-registry 修改 binaryPaths 后内核更新随之安装到新路径。
+func serviceBinaryTarget(mgr *service.Manager, name string) func() string {
+	return func() string {
+		spec, err := mgr.Spec(name)
+		if err != nil {
+			return ""
+		}
+		return primaryBinary(spec.BinaryPaths)
+	}
+}
@@ -104,7 +104,8 @@ func extractTimestamp(line string) (time.Time, string, bool) {
 	}
 	if len(fields) >= 2 {
 		candidate := fields[0] + " " + fields[1]
-		if ts, err := time.Parse("2006/01/02 15:04:05", candidate); err == nil {
+		// 该格式（Go log 与 HeroBox 输出标记行）不带时区，按本地时间解析。
+		if ts, err := time.ParseInLocation("2006/01/02 15:04:05", candidate, time.Local); err == nil {
 			rest := strings.TrimPrefix(line, candidate)
 			return ts, rest, true
 		}
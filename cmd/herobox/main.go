@@ -20,6 +20,8 @@ import (
 	"github.com/herozmy/herobox/internal/config"
 	"github.com/herozmy/herobox/internal/logs"
 	"github.com/herozmy/herobox/internal/mosdns"
+	"github.com/herozmy/herobox/internal/outbound"
+	"github.com/herozmy/herobox/internal/procstat"
 	"github.com/herozmy/herobox/internal/service"
 )
 
@@ -62,8 +64,22 @@ func main() {
 	logBuffer := logs.NewBuffer(500)
 	logs.SetBuffer(logBuffer)
 
-	mosdnsHooks := newMosdnsHooks(configStore)
-	mosdnsBinaryPaths = binaryCandidates("MOSDNS_BIN", "/usr/local/bin/mosdns")
+	serviceEvents = newServiceEvents()
+	configureOutbound(configStore)
+
+	// 服务列表由内置核心与 herobox.yaml services 段合并而来，可通过 /api/service-registry 在运行时增删。
+	var specs []service.ServiceSpec
+	var directServices []string
+	binaries := make(map[string][]string)
+	for _, def := range effectiveServiceDefinitions(configStore) {
+		spec := buildServiceSpec(def, configStore)
+		specs = append(specs, spec)
+		binaries[def.Name] = def.BinaryPaths
+		if isExecBackend(spec) {
+			directServices = append(directServices, def.Name)
+		}
+	}
+	mosdnsBinaryPaths = binaries["mosdns"]
 	if configStore.MosdnsVersion() == "" {
 		refreshMosdnsVersion(configStore, mosdnsBinaryPaths)
 	}
@@ -71,28 +87,37 @@ func main() {
 		log.Printf("初始化 config_overrides.json 失败: %v", err)
 	}
 
-	svcManager := service.NewManager([]service.ServiceSpec{
-		{
-			Name:        "mosdns",
-			Unit:        getenv("MOSDNS_UNIT", "mosdns.service"),
-			BinaryPaths: mosdnsBinaryPaths,
-			Hooks:       mosdnsHooks,
-		},
-		{
-			Name:        "sing-box",
-			Unit:        getenv("SING_BOX_UNIT", "sing-box.service"),
-			BinaryPaths: binaryCandidates("SING_BOX_BIN", "/usr/local/bin/sing-box"),
-		},
-		{
-			Name:        "mihomo",
-			Unit:        getenv("MIHOMO_UNIT", "mihomo.service"),
-			BinaryPaths: binaryCandidates("MIHOMO_BIN", "/usr/local/bin/mihomo"),
-		},
-	})
+	svcManager := service.NewManager(specs)
+	svcManager.ProbeTimeout = envDuration("HEROBOX_STATUS_TIMEOUT", 3*time.Second)
+	svcManager.Events = serviceEvents
+	snapshots := newConfigSnapshots(filepath.Join(heroboxDataDir(), "snapshots"))
+	go func() {
+		autostartServices(svcManager, configStore, directServices...)
+		seedConfigSnapshots(svcManager, configStore, snapshots)
+	}()
+
+	sampler := procstat.NewSampler(
+		envDuration("HEROBOX_METRICS_INTERVAL", 10*time.Second),
+		envDuration("HEROBOX_METRICS_RETENTION", time.Hour),
+	)
+	samplerCtx, stopSampler := context.WithCancel(context.Background())
+	defer stopSampler()
+	go sampler.Run(samplerCtx, servicePIDResolver(svcManager, configStore))
+
 	updater := mosdns.DefaultUpdater()
 	if updater.InstallDir == "" {
 		updater.InstallDir = filepath.Join(".", "bin")
 	}
+	updater.Backups = &mosdns.Backups{
+		Keep: envInt("HEROBOX_KERNEL_BACKUPS", 3),
+		Version: func(path string) (string, error) {
+			return detectMosdnsVersion([]string{path})
+		},
+	}
+	singBoxUpdater := mosdns.NewSingBoxUpdater(primaryBinary(binaries["sing-box"]))
+	singBoxUpdater.TargetPath = serviceBinaryTarget(svcManager, "sing-box")
+	mihomoUpdater := mosdns.NewMihomoUpdater(primaryBinary(binaries["mihomo"]))
+	mihomoUpdater.TargetPath = serviceBinaryTarget(svcManager, "mihomo")
 	configArchiveURL := getenv("MOSDNS_CONFIG_ARCHIVE", defaultConfigArchive)
 
 	mux := http.NewServeMux()
@@ -135,20 +160,16 @@ func main() {
 			methodNotAllowed(w)
 			return
 		}
-		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-		defer cancel()
-		snaps, err := svcManager.List(ctx)
-		if err != nil {
-			respondErr(w, err)
-			return
-		}
+		snaps := svcManager.List(r.Context())
 		updateMosdnsState(configStore, mosdnsBinaryPaths, snaps...)
 		for i := range snaps {
 			applyMosdnsVersion(configStore, &snaps[i])
 		}
 		respondJSON(w, snaps)
 	})
-	mux.Handle("/api/services/", http.StripPrefix("/api/services", serviceHandler(svcManager, configStore)))
+	mux.Handle("/api/services/", http.StripPrefix("/api/services", serviceHandler(svcManager, configStore, sampler, snapshots)))
+	mux.Handle("/api/service-registry", serviceRegistryHandler(svcManager, configStore))
+	mux.Handle("/api/service-registry/", serviceRegistryHandler(svcManager, configStore))
 
 	mux.HandleFunc("/api/mosdns/kernel/latest", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
@@ -184,6 +205,14 @@ func main() {
 		})
 	})
 
+	refreshVersion := func() { refreshMosdnsVersion(configStore, mosdnsBinaryPaths) }
+	registerKernelReleaseRoutes(mux, "/api/mosdns/kernel", updater, refreshVersion)
+	registerKernelBackupRoutes(mux, "/api/mosdns/kernel", updater, svcManager, "mosdns", refreshVersion)
+	registerMosdnsProgressRoutes(mux, updater, svcManager, configStore, configArchiveURL, refreshVersion)
+
+	registerKernelRoutes(mux, "/api/sing-box/kernel", singBoxUpdater)
+	registerKernelRoutes(mux, "/api/mihomo/kernel", mihomoUpdater)
+
 	mux.HandleFunc("/api/mosdns/config", func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
@@ -215,33 +244,11 @@ func main() {
 		}
 		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Minute)
 		defer cancel()
-		targetDir := resolveConfigDir(configStore.GetConfigPath())
-		if err := downloadAndExtractConfig(ctx, configArchiveURL, targetDir); err != nil {
-			respondErr(w, err)
-			return
-		}
-		placeholderCount, err := rewriteConfigValue(targetDir, placeholderMosdnsDir, targetDir)
+		status, err := downloadMosdnsConfig(ctx, configStore, configArchiveURL)
 		if err != nil {
 			respondErr(w, err)
 			return
 		}
-		guideSteps := []map[string]any{
-			buildGuideStep("步骤1：同步配置目录", placeholderCount, placeholderMosdnsDir, targetDir),
-			{
-				"title":   "步骤2：自定义设置已迁移到 config_overrides.json",
-				"detail":  "后续 FakeIP、DNS、SOCKS5 等自定义设置将仅通过 overrides 生效，不再直接修改 mosdns 配置文件。",
-				"success": false,
-			},
-		}
-		status := buildConfigStatus(configStore.GetConfigPath())
-		status["placeholder"] = placeholderMosdnsDir
-		status["replacement"] = targetDir
-		status["rewritten"] = placeholderCount
-		status["guideSteps"] = guideSteps
-		// 下载配置仅同步基础目录等信息，自定义设置依赖 config_overrides.json，由 syncConfigOverrides 负责写入。
-		if err := syncConfigOverrides(configStore); err != nil {
-			log.Printf("sync config overrides failed: %v", err)
-		}
 		respondJSON(w, status)
 	})
 
@@ -480,10 +487,15 @@ func main() {
 				respondErr(w, fmt.Errorf("无效的请求体: %w", err))
 				return
 			}
+			if err := validateOutboundSettings(configStore, payload); err != nil {
+				respondErr(w, err)
+				return
+			}
 			if err := configStore.UpdateSettings(payload); err != nil {
 				respondErr(w, err)
 				return
 			}
+			configureOutbound(configStore)
 			// 根据当前 SOCKS5 设置，在 mosdns 配置文件中注释或恢复 SOCKS5 相关行。
 			cfgDir := resolveConfigDir(configStore.GetConfigPath())
 			if count, err := toggleSocks5References(cfgDir, resolveSocks5Enabled(configStore)); err != nil {
@@ -525,7 +537,7 @@ func main() {
 	}
 }
 
-func serviceHandler(mgr *service.Manager, store *config.Store) http.Handler {
+func serviceHandler(mgr *service.Manager, store *config.Store, sampler *procstat.Sampler, snapshots *configSnapshots) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		path := strings.Trim(r.URL.Path, "/")
 		if path == "" {
@@ -536,6 +548,23 @@ func serviceHandler(mgr *service.Manager, store *config.Store) http.Handler {
 		name := parts[0]
 		switch r.Method {
 		case http.MethodGet:
+			if len(parts) > 1 {
+				switch parts[1] {
+				case "output":
+					serveServiceOutput(w, r, mgr, name)
+				case "journal":
+					serveServiceJournal(w, r, mgr, name)
+				case "metrics":
+					serveServiceMetrics(w, r, mgr, sampler, name)
+				case "unit":
+					serveServiceUnit(w, r, mgr, name)
+				case "events":
+					serveServiceEvents(w, r, mgr, name)
+				default:
+					http.NotFound(w, r)
+				}
+				return
+			}
 			ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 			defer cancel()
 			snap, err := mgr.Status(ctx, name)
@@ -548,11 +577,20 @@ func serviceHandler(mgr *service.Manager, store *config.Store) http.Handler {
 			respondJSON(w, snap)
 		case http.MethodPost:
 			if len(parts) < 2 {
-				respondErr(w, errors.New("缺少操作动作，如 start/stop"))
+				respondErr(w, errors.New("缺少操作动作，如 start/stop/enable"))
 				return
 			}
 			action := parts[1]
-			ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+			if name == allServicesName {
+				serveServiceGroup(w, r, mgr, store, action)
+				return
+			}
+			if action == "safe-restart" {
+				serveSafeRestart(w, r, mgr, store, snapshots, name)
+				return
+			}
+			// stop/restart 需要等待进程退出，超时包含停止宽限期与 SIGKILL 等待。
+			ctx, cancel := context.WithTimeout(service.WithCause(r.Context(), service.CauseAPI), stopGracePeriod()+killWait+10*time.Second)
 			defer cancel()
 			var err error
 			switch action {
@@ -562,6 +600,10 @@ func serviceHandler(mgr *service.Manager, store *config.Store) http.Handler {
 				err = mgr.Stop(ctx, name)
 			case "restart":
 				err = mgr.Restart(ctx, name)
+			case "enable":
+				err = mgr.Enable(ctx, name, true)
+			case "disable":
+				err = mgr.Enable(ctx, name, false)
 			default:
 				respondErr(w, fmt.Errorf("不支持的操作 %s", action))
 				return
@@ -578,6 +620,12 @@ func serviceHandler(mgr *service.Manager, store *config.Store) http.Handler {
 			updateMosdnsState(store, mosdnsBinaryPaths, snap)
 			applyMosdnsVersion(store, &snap)
 			respondJSON(w, snap)
+		case http.MethodPut:
+			if len(parts) > 1 && parts[1] == "unit" {
+				serveServiceUnit(w, r, mgr, name)
+				return
+			}
+			methodNotAllowed(w)
 		default:
 			methodNotAllowed(w)
 		}
@@ -608,7 +656,13 @@ func respondErr(w http.ResponseWriter, err error) {
 	log.Printf("api error: %v", err)
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(http.StatusBadRequest)
-	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+	payload := map[string]string{"error": err.Error()}
+	var checkErr *service.CheckError
+	if errors.As(err, &checkErr) {
+		payload["checkCommand"] = checkErr.Command
+		payload["checkOutput"] = checkErr.Output
+	}
+	_ = json.NewEncoder(w).Encode(payload)
 }
 
 func methodNotAllowed(w http.ResponseWriter) {
@@ -798,6 +852,38 @@ func normalizeMosdnsVersion(raw string) string {
 	return trimmed
 }
 
+// downloadMosdnsConfig 下载并解压预设配置到当前配置目录，替换占位目录后同步 overrides，返回配置状态与引导步骤。
+func downloadMosdnsConfig(ctx context.Context, store *config.Store, archiveURL string) (map[string]any, error) {
+	targetDir := resolveConfigDir(store.GetConfigPath())
+	if err := downloadAndExtractConfig(ctx, archiveURL, targetDir); err != nil {
+		return nil, err
+	}
+	logs.InfofContext(ctx, "[mosdns] 已下载预设配置 %s -> %s", archiveURL, targetDir)
+	mosdns.ReportProgress(ctx, mosdns.Progress{Phase: mosdns.PhaseInstall, Message: targetDir})
+	placeholderCount, err := rewriteConfigValue(targetDir, placeholderMosdnsDir, targetDir)
+	if err != nil {
+		return nil, err
+	}
+	guideSteps := []map[string]any{
+		buildGuideStep("步骤1：同步配置目录", placeholderCount, placeholderMosdnsDir, targetDir),
+		{
+			"title":   "步骤2：自定义设置已迁移到 config_overrides.json",
+			"detail":  "后续 FakeIP、DNS、SOCKS5 等自定义设置将仅通过 overrides 生效，不再直接修改 mosdns 配置文件。",
+			"success": false,
+		},
+	}
+	status := buildConfigStatus(store.GetConfigPath())
+	status["placeholder"] = placeholderMosdnsDir
+	status["replacement"] = targetDir
+	status["rewritten"] = placeholderCount
+	status["guideSteps"] = guideSteps
+	// 下载配置仅同步基础目录等信息，自定义设置依赖 config_overrides.json，由 syncConfigOverrides 负责写入。
+	if err := syncConfigOverrides(store); err != nil {
+		log.Printf("sync config overrides failed: %v", err)
+	}
+	return status, nil
+}
+
 func downloadAndExtractConfig(ctx context.Context, url, targetDir string) error {
 	if url == "" {
 		return fmt.Errorf("未配置 mosdns 配置下载地址")
@@ -812,7 +898,7 @@ func downloadAndExtractConfig(ctx context.Context, url, targetDir string) error
 	if err != nil {
 		return err
 	}
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := outbound.Default.Download(req)
 	if err != nil {
 		return err
 	}
@@ -828,12 +914,13 @@ func downloadAndExtractConfig(ctx context.Context, url, targetDir string) error
 		tempFile.Close()
 		os.Remove(tempFile.Name())
 	}()
-	if _, err := io.Copy(tempFile, resp.Body); err != nil {
+	if _, err := io.Copy(tempFile, mosdns.ProgressReader(ctx, resp.Body, resp.ContentLength)); err != nil {
 		return err
 	}
 	if err := tempFile.Close(); err != nil {
 		return err
 	}
+	mosdns.ReportProgress(ctx, mosdns.Progress{Phase: mosdns.PhaseExtract, Message: targetDir})
 	return extractConfigZip(tempFile.Name(), targetDir)
 }
 
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/herozmy/herobox/internal/config"
+	"github.com/herozmy/herobox/internal/procstat"
+	"github.com/herozmy/herobox/internal/service"
+)
+
+// servicePIDResolver 返回各服务当前 PID：优先使用快照中的 PID（systemd MainPID 或看护进程），
+// 否则回退到 herobox.yaml 中记录的 PID。
+func servicePIDResolver(mgr *service.Manager, store *config.Store) func(ctx context.Context) map[string]int {
+	return func(ctx context.Context) map[string]int {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		snaps := mgr.List(ctx)
+		pids := make(map[string]int, len(snaps))
+		for _, snap := range snaps {
+			pid := snap.PID
+			if pid <= 0 && snap.Status.Active() {
+				if stored := store.ServicePID(snap.Name); processRunning(stored) {
+					pid = stored
+				}
+			}
+			pids[snap.Name] = pid
+		}
+		return pids
+	}
+}
+
+// serveServiceMetrics 处理 GET /api/services/{name}/metrics?window=15m。
+func serveServiceMetrics(w http.ResponseWriter, r *http.Request, mgr *service.Manager, sampler *procstat.Sampler, name string) {
+	spec, err := mgr.Spec(name)
+	if err != nil {
+		respondErr(w, err)
+		return
+	}
+	var window time.Duration
+	if raw := r.URL.Query().Get("window"); raw != "" {
+		window, err = time.ParseDuration(raw)
+		if err != nil {
+			respondErr(w, err)
+			return
+		}
+	}
+	respondJSON(w, map[string]any{
+		"name":     spec.Name,
+		"interval": sampler.Interval.String(),
+		"samples":  sampler.History(spec.Name, window),
+	})
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/herozmy/herobox/internal/config"
+	"github.com/herozmy/herobox/internal/logs"
+	"github.com/herozmy/herobox/internal/outbound"
+)
+
+// 出站相关设置项，未设置时回退到同名环境变量。
+const (
+	settingDownloadProxy = "downloadProxy" // HEROBOX_PROXY
+	settingGithubMirror  = "githubMirror"  // HEROBOX_GITHUB_MIRROR
+	settingURLRewrites   = "urlRewrites"   // HEROBOX_URL_REWRITES
+)
+
+// outboundProxySocks5 表示复用 socks5Address 设置作为下载代理。
+const outboundProxySocks5 = "socks5"
+
+// configureOutbound 让内核更新、配置下载等出站请求按当前设置选择代理与镜像，
+// 在启动与每次保存设置后调用，规则只在此时解析一次。
+func configureOutbound(store *config.Store) {
+	outbound.Default.SetOptions(outboundOptions(store))
+}
+
+// outboundOptions 读取出站选项：
+//   - downloadProxy：空为沿用 HTTP_PROXY 等环境变量，direct 直连，socks5 复用 socks5Address 设置，
+//     也可填写 http://、socks5:// 等代理地址
+//   - githubMirror：ghproxy 式镜像前缀，github.com 与 raw.githubusercontent.com 的地址改写为 前缀+原地址
+//   - urlRewrites：前缀=替换 规则（换行或逗号分隔），优先于镜像前缀，可用于 api.github.com
+//
+// 超时由 HEROBOX_HTTP_TIMEOUT（API 与校验文件，默认 15s）与 HEROBOX_DOWNLOAD_TIMEOUT（资产下载，默认仅受接口超时限制）控制。
+func outboundOptions(store *config.Store) outbound.Options {
+	opts := outbound.Options{
+		Proxy:           resolveOutboundProxy(store),
+		Timeout:         envDuration("HEROBOX_HTTP_TIMEOUT", outbound.DefaultTimeout),
+		DownloadTimeout: envDuration("HEROBOX_DOWNLOAD_TIMEOUT", 0),
+	}
+	rules, err := outbound.ParseRewrites(resolveSetting(store, settingURLRewrites, getenv("HEROBOX_URL_REWRITES", "")))
+	if err != nil {
+		logs.Errorf("[outbound] 忽略改写规则: %v", err)
+	}
+	opts.Rewrites = append(rules, outbound.MirrorRewrites(resolveSetting(store, settingGithubMirror, getenv("HEROBOX_GITHUB_MIRROR", "")))...)
+	return opts
+}
+
+func resolveOutboundProxy(store *config.Store) string {
+	proxy := resolveSetting(store, settingDownloadProxy, getenv("HEROBOX_PROXY", ""))
+	if !strings.EqualFold(proxy, outboundProxySocks5) {
+		return proxy
+	}
+	addr := resolveSocks5Address(store)
+	if addr == "" {
+		// 例如 HEROBOX_PROXY=socks5 但未配置 socks5Address，回退到环境变量代理而不是让所有下载失败。
+		logs.Errorf("[outbound] 下载代理为 socks5 但未设置 socks5Address，改用环境变量代理")
+		return outbound.ProxyEnv
+	}
+	if strings.Contains(addr, "://") {
+		return addr
+	}
+	// socks5h 由代理解析域名，避免本地 DNS 污染。
+	return "socks5h://" + addr
+}
+
+// validateOutboundSettings 在保存设置前校验出站相关项（values 为本次提交的设置），避免保存后所有下载才失败。
+func validateOutboundSettings(store *config.Store, values map[string]string) error {
+	if proxy, ok := values[settingDownloadProxy]; ok {
+		proxy = strings.TrimSpace(proxy)
+		switch {
+		case proxy == "", strings.EqualFold(proxy, outbound.ProxyDirect), strings.EqualFold(proxy, outboundProxySocks5):
+		default:
+			if _, err := outbound.ParseProxy(proxy); err != nil {
+				return err
+			}
+		}
+	}
+	// downloadProxy=socks5 依赖 socks5Address，两者任一在本次提交中修改都需要按合并后的结果检查。
+	proxy := resolveSetting(store, settingDownloadProxy, getenv("HEROBOX_PROXY", ""))
+	if value, ok := values[settingDownloadProxy]; ok {
+		proxy = strings.TrimSpace(value)
+	}
+	addr := resolveSocks5Address(store)
+	if value, ok := values["socks5Address"]; ok {
+		addr = strings.TrimSpace(value)
+	}
+	if strings.EqualFold(proxy, outboundProxySocks5) && addr == "" {
+		return errors.New("下载代理设为 socks5 时需要先填写 SOCKS5 地址（socks5Address）")
+	}
+	if rules, ok := values[settingURLRewrites]; ok {
+		if _, err := outbound.ParseRewrites(rules); err != nil {
+			return err
+		}
+	}
+	if mirror, ok := values[settingGithubMirror]; ok && strings.TrimSpace(mirror) != "" {
+		if _, err := outbound.ParseRewrites("https://github.com/=" + strings.TrimSpace(mirror)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
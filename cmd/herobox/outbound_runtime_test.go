@@ -0,0 +1,46 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/herozmy/herobox/internal/config"
+	"github.com/herozmy/herobox/internal/outbound"
+)
+
+func TestOutboundSocks5RequiresAddress(t *testing.T) {
+	dir := t.TempDir()
+	store, err := config.NewStore(filepath.Join(dir, "config.yaml"), filepath.Join(dir, "herobox.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.UpdateSettings(map[string]string{"socks5Address": ""}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := validateOutboundSettings(store, map[string]string{settingDownloadProxy: "socks5"}); err == nil {
+		t.Fatal("socks5 download proxy accepted without socks5Address")
+	}
+	if err := validateOutboundSettings(store, map[string]string{settingDownloadProxy: "socks5", "socks5Address": "127.0.0.1:1080"}); err != nil {
+		t.Fatalf("socks5 with address in the same payload: %v", err)
+	}
+
+	if err := store.UpdateSettings(map[string]string{settingDownloadProxy: "socks5", "socks5Address": "127.0.0.1:1080"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := resolveOutboundProxy(store); got != "socks5h://127.0.0.1:1080" {
+		t.Fatalf("proxy = %q", got)
+	}
+	if err := validateOutboundSettings(store, map[string]string{"socks5Address": ""}); err == nil {
+		t.Fatal("clearing socks5Address accepted while download proxy is socks5")
+	}
+
+	// 环境变量设置的 socks5 无法在保存时拦截，运行时回退到环境变量代理。
+	t.Setenv("HEROBOX_PROXY", "socks5")
+	if err := store.UpdateSettings(map[string]string{settingDownloadProxy: "", "socks5Address": ""}); err != nil {
+		t.Fatal(err)
+	}
+	if got := resolveOutboundProxy(store); got != outbound.ProxyEnv {
+		t.Fatalf("proxy = %q, want environment fallback", got)
+	}
+}
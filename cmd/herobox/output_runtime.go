@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/herozmy/herobox/internal/logs"
+	"github.com/herozmy/herobox/internal/service"
+)
+
+// maxOutputTailBytes 限制单次读取的尾部字节数，避免大文件拖慢接口。
+const maxOutputTailBytes = 1 << 20
+
+// outputTimeLayout 是 herobox 写入输出文件的标记行时间格式，可被 extractTimestamp 解析。
+const outputTimeLayout = "2006/01/02 15:04:05"
+
+// serviceOutputPath 返回直接拉起的核心 stdout/stderr 所在文件，例如 /var/log/herobox/mosdns.out。
+func serviceOutputPath(name string) string {
+	dir := getenv("HEROBOX_OUTPUT_DIR", "/var/log/herobox")
+	return filepath.Join(dir, strings.ToLower(name)+".out")
+}
+
+// openServiceOutput 打开按大小滚动的输出文件，失败时退回 HeroBox 自身的标准输出。
+func openServiceOutput(name string) io.Writer {
+	maxSize := int64(envInt("HEROBOX_OUTPUT_MAX_MB", 5)) << 20
+	backups := envInt("HEROBOX_OUTPUT_BACKUPS", 3)
+	path := serviceOutputPath(name)
+	file, err := logs.NewRotatingFile(path, maxSize, backups)
+	if err != nil {
+		logs.Errorf("[service] 打开 %s 输出文件 %s 失败，改为输出到终端: %v", name, path, err)
+		return os.Stdout
+	}
+	return file
+}
+
+// tailServiceOutput 读取输出文件最后 limit 行，当前文件不足时补充最近一次滚动的 .1 文件。
+func tailServiceOutput(path string, limit int) ([]string, error) {
+	if limit <= 0 {
+		limit = 200
+	}
+	lines, err := tailFileLines(path, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) < limit {
+		if older, err := tailFileLines(path+".1", limit-len(lines)); err == nil {
+			lines = append(older, lines...)
+		}
+	}
+	return lines, nil
+}
+
+func tailFileLines(path string, limit int) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	offset := info.Size() - maxOutputTailBytes
+	if offset < 0 {
+		offset = 0
+	}
+	data := make([]byte, info.Size()-offset)
+	if _, err := f.ReadAt(data, offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if offset > 0 {
+		// 丢弃被截断的首行。
+		if idx := bytes.IndexByte(data, '\n'); idx >= 0 {
+			data = data[idx+1:]
+		}
+	}
+	lines := make([]string, 0, limit)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxOutputTailBytes)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		if len(lines) > limit {
+			lines = lines[1:]
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// serveServiceOutput 处理 GET /api/services/{name}/output?lines=N。
+func serveServiceOutput(w http.ResponseWriter, r *http.Request, mgr *service.Manager, name string) {
+	spec, err := mgr.Spec(name)
+	if err != nil {
+		respondErr(w, err)
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("lines"))
+	if limit <= 0 || limit > 2000 {
+		limit = 400
+	}
+	path := serviceOutputPath(spec.Name)
+	lines, err := tailServiceOutput(path, limit)
+	if err != nil {
+		if os.IsNotExist(err) {
+			respondJSON(w, map[string]any{"entries": []logs.Entry{}, "file": path})
+			return
+		}
+		respondErr(w, err)
+		return
+	}
+	entries := make([]logs.Entry, 0, len(lines))
+	for _, line := range lines {
+		if entry, ok := parseOutputLine(line); ok {
+			entries = append(entries, entry)
+		}
+	}
+	respondJSON(w, map[string]any{
+		"entries": entries,
+		"file":    path,
+	})
+}
+
+// parseOutputLine 解析输出文件中的一行；核心自身的输出格式各异，无法识别时间时不填 timestamp，
+// 避免把历史输出显示为读取时刻。
+func parseOutputLine(line string) (logs.Entry, bool) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" {
+		return logs.Entry{}, false
+	}
+	entry := logs.Entry{Level: "info", Message: trimmed}
+	if ts, rest, ok := extractTimestamp(trimmed); ok {
+		entry.Timestamp = ts
+		entry.Message = strings.TrimSpace(rest)
+	}
+	return entry, true
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseOutputLine(t *testing.T) {
+	marker := time.Date(2026, 3, 1, 8, 30, 15, 0, time.Local)
+	cases := []struct {
+		name    string
+		line    string
+		want    time.Time
+		message string
+	}{
+		{"marker in local time", marker.Format(outputTimeLayout) + " [herobox] 启动 mosdns (PID 42)", marker, "[herobox] 启动 mosdns (PID 42)"},
+		{"zoned timestamp", "2026-03-01T08:30:15.000+0800 INFO ready", time.Date(2026, 3, 1, 0, 30, 15, 0, time.UTC), "INFO ready"},
+		{"unparseable", "+0800 INFO inbound started", time.Time{}, "+0800 INFO inbound started"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			entry, ok := parseOutputLine(tc.line)
+			if !ok {
+				t.Fatal("line was skipped")
+			}
+			if !entry.Timestamp.Equal(tc.want) {
+				t.Fatalf("timestamp = %v, want %v", entry.Timestamp, tc.want)
+			}
+			if entry.Message != tc.message {
+				t.Fatalf("message = %q, want %q", entry.Message, tc.message)
+			}
+		})
+	}
+	if _, ok := parseOutputLine("   "); ok {
+		t.Fatal("blank line should be skipped")
+	}
+}
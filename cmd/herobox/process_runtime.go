@@ -4,7 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"net"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -13,68 +13,59 @@ import (
 	"time"
 
 	"github.com/herozmy/herobox/internal/config"
+	"github.com/herozmy/herobox/internal/logs"
 	"github.com/herozmy/herobox/internal/service"
 )
 
-func newMosdnsHooks(store *config.Store) service.ServiceHooks {
+// mosdnsArgs 根据当前配置路径计算 mosdns 启动参数，直接执行与生成 systemd unit 共用。
+func mosdnsArgs(store *config.Store) func() []string {
 	defaultDataDir := getenv("MOSDNS_DATA_DIR", "")
-	return service.ServiceHooks{
+	return func() []string {
+		cfg := store.GetConfigPath()
+		dataDir := resolveMosdnsDataDir(defaultDataDir, cfg)
+		return []string{"start", "-c", cfg, "-d", dataDir}
+	}
+}
+
+func newMosdnsHooks(store *config.Store, binaryPaths []string) service.ServiceHooks {
+	buildArgs := mosdnsArgs(store)
+	supervisor := newProcessSupervisor("mosdns", store, func(out io.Writer) (*exec.Cmd, error) {
+		binary, err := firstExistingBinary(binaryPaths)
+		if err != nil {
+			return nil, err
+		}
+		return startProcess(binary, out, buildArgs()...)
+	})
+	adopter := newProcessAdopter("mosdns", store, binaryPaths, store.GetConfigPath)
+	return withAutostart(store, service.ServiceHooks{
 		Start: func(ctx context.Context, spec service.ServiceSpec) error {
-			binary, err := firstExistingBinary(spec.BinaryPaths)
-			if err != nil {
-				return err
-			}
-			cfg := store.GetConfigPath()
-			dataDir := resolveMosdnsDataDir(defaultDataDir, cfg)
-			pid, err := runCommandDetached(binary, "start", "-c", cfg, "-d", dataDir)
-			if err != nil {
-				return err
+			if !supervisor.Running() {
+				if pid := adopter.Adopt(); pid > 0 {
+					return fmt.Errorf("mosdns 已在运行 (PID %d)", pid)
+				}
 			}
-			return store.SetMosdnsPID(pid)
+			return supervisor.Start()
 		},
 		Stop: func(ctx context.Context, spec service.ServiceSpec) error {
-			pid := store.MosdnsPID()
-			if pid <= 0 {
-				return errors.New("未找到 mosdns 进程 PID")
-			}
-			if err := terminateProcess(pid); err != nil {
-				return err
-			}
-			return store.SetMosdnsPID(0)
+			return stopSupervised(ctx, supervisor, adopter, spec.Name)
 		},
 		Restart: func(ctx context.Context, spec service.ServiceSpec) error {
-			if pid := store.MosdnsPID(); pid > 0 {
-				_ = terminateProcess(pid)
-			}
-			binary, err := firstExistingBinary(spec.BinaryPaths)
-			if err != nil {
-				return err
-			}
-			cfg := store.GetConfigPath()
-			dataDir := resolveMosdnsDataDir(defaultDataDir, cfg)
-			pid, err := runCommandDetached(binary, "start", "-c", cfg, "-d", dataDir)
-			if err != nil {
-				return err
-			}
-			return store.SetMosdnsPID(pid)
+			return restartSupervised(ctx, supervisor, adopter)
 		},
 		Status: func(ctx context.Context, spec service.ServiceSpec) (service.Status, error) {
-			if pid := store.MosdnsPID(); pid > 0 {
-				if processAlive(pid) {
-					return service.StatusRunning, nil
-				}
-				_ = store.SetMosdnsPID(0)
-			}
-			ctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
-			defer cancel()
-			host := resolveMosdnsPluginHost(store)
-			port := resolveMosdnsPluginPort(store)
-			if isMosdnsAPIAlive(ctx, host, port) {
+			// 只以看护或接管到的进程判断运行状态：插件端口能建立 TCP 连接不代表 mosdns 在运行（可能是其他进程占用），
+			// 实际能否解析由 DNS 健康探测判断。
+			pid := adopter.Adopt()
+			if supervisor.Running() || pid > 0 {
 				return service.StatusRunning, nil
 			}
 			return service.StatusStopped, nil
 		},
-	}
+		Annotate: func(spec service.ServiceSpec, snap *service.Snapshot) {
+			supervisor.Annotate(snap)
+			adopter.Annotate(snap)
+		},
+	})
 }
 
 func processAlive(pid int) bool {
@@ -91,17 +82,6 @@ func processAlive(pid int) bool {
 	return proc.Signal(syscall.Signal(0)) == nil
 }
 
-func isMosdnsAPIAlive(ctx context.Context, host, port string) bool {
-	addr := net.JoinHostPort(host, port)
-	dialer := &net.Dialer{}
-	conn, err := dialer.DialContext(ctx, "tcp", addr)
-	if err != nil {
-		return false
-	}
-	conn.Close()
-	return true
-}
-
 func resolveMosdnsDataDir(envDir, configPath string) string {
 	if envDir != "" {
 		return envDir
@@ -135,18 +115,15 @@ func runCommand(ctx context.Context, binary string, args ...string) error {
 	return cmd.Run()
 }
 
-func runCommandDetached(binary string, args ...string) (int, error) {
+// startProcess 以子进程方式启动核心，stdout/stderr 写入 out，调用方负责 Wait 回收。
+func startProcess(binary string, out io.Writer, args ...string) (*exec.Cmd, error) {
 	cmd := exec.Command(binary, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = out
+	cmd.Stderr = out
 	if err := cmd.Start(); err != nil {
-		return 0, err
-	}
-	pid := cmd.Process.Pid
-	if err := cmd.Process.Release(); err != nil {
-		return pid, err
+		return nil, err
 	}
-	return pid, nil
+	return cmd, nil
 }
 
 func terminateProcess(pid int) error {
@@ -159,3 +136,56 @@ func terminateProcess(pid int) error {
 	}
 	return proc.Signal(syscall.SIGTERM)
 }
+
+// stopGracePeriod 为停止核心时等待其自行退出的时间，超时后发送 SIGKILL。
+func stopGracePeriod() time.Duration {
+	return envDuration("HEROBOX_STOP_GRACE", 10*time.Second)
+}
+
+// killWait 为发送 SIGKILL 后等待内核回收进程的时间。
+const killWait = 5 * time.Second
+
+// stopProcess 向非 HeroBox 子进程发送 SIGTERM，等待 grace 后仍未退出则 SIGKILL，
+// 返回是否动用了 SIGKILL；进程最终仍存活时返回错误。
+func stopProcess(ctx context.Context, name string, pid int, grace time.Duration) (bool, error) {
+	if err := terminateProcess(pid); err != nil {
+		if !processRunning(pid) {
+			return false, nil
+		}
+		return false, err
+	}
+	if waitProcessExit(ctx, pid, grace) {
+		return false, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return false, fmt.Errorf("等待 %s (PID %d) 退出时中断: %w", name, pid, err)
+	}
+	logs.Errorf("[service] %s (PID %d) 未在 %s 内退出，发送 SIGKILL", name, pid, grace)
+	if proc, err := os.FindProcess(pid); err == nil {
+		_ = proc.Signal(syscall.SIGKILL)
+	}
+	if waitProcessExit(ctx, pid, killWait) {
+		return true, nil
+	}
+	return true, fmt.Errorf("%s (PID %d) 在 SIGKILL 后仍未退出", name, pid)
+}
+
+// waitProcessExit 轮询进程表直到进程退出、超时或 ctx 结束，返回进程是否已退出。
+func waitProcessExit(ctx context.Context, pid int, timeout time.Duration) bool {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if !processRunning(pid) {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return !processRunning(pid)
+		case <-deadline.C:
+			return !processRunning(pid)
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/herozmy/herobox/internal/config"
+	"github.com/herozmy/herobox/internal/service"
+)
+
+func TestMosdnsStatusIgnoresOpenPluginPort(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	t.Setenv("MOSDNS_STATUS_HOST", host)
+	t.Setenv("MOSDNS_PLUGIN_PORT", port)
+
+	dir := t.TempDir()
+	store, err := config.NewStore(filepath.Join(dir, "config.yaml"), filepath.Join(dir, "herobox.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hooks := newMosdnsHooks(store, []string{filepath.Join(dir, "mosdns")})
+	status, err := hooks.Status(context.Background(), service.ServiceSpec{Name: "mosdns"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != service.StatusStopped {
+		t.Fatalf("status = %s with only the plugin port open, want stopped", status)
+	}
+}
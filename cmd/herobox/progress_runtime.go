@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/herozmy/herobox/internal/config"
+	"github.com/herozmy/herobox/internal/logs"
+	"github.com/herozmy/herobox/internal/mosdns"
+	"github.com/herozmy/herobox/internal/service"
+)
+
+// sseKeepAlive 为 SSE 心跳间隔，避免反向代理在下载缓慢时断开空闲连接。
+const sseKeepAlive = 15 * time.Second
+
+// streamSeq 为每个进度流分配操作标记，日志按标记只转发给发起该操作的连接。
+var streamSeq atomic.Uint64
+
+type sseEvent struct {
+	name string
+	data any
+}
+
+// registerMosdnsProgressRoutes 注册内核更新与配置下载的 SSE 接口，EventSource 只能发起 GET，因此均为 GET：
+//   - /api/mosdns/kernel/update/stream?tag=&restart=true：tag 为空时更新到最新版本
+//   - /api/mosdns/config/download/stream?restart=true
+//
+// restart=true 时在 mosdns 运行中完成后重启。
+func registerMosdnsProgressRoutes(mux *http.ServeMux, updater *mosdns.Updater, mgr *service.Manager, store *config.Store, archiveURL string, installed func()) {
+	mux.HandleFunc("/api/mosdns/kernel/update/stream", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w)
+			return
+		}
+		q := r.URL.Query()
+		tag := strings.TrimSpace(q.Get("tag"))
+		restart, _ := strconv.ParseBool(q.Get("restart"))
+		serveProgressStream(w, r, func(ctx context.Context) (any, error) {
+			opCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+			defer cancel()
+			var (
+				rel  *mosdns.Release
+				path string
+				err  error
+			)
+			if tag == "" {
+				rel, path, err = updater.UpdateLatest(opCtx)
+			} else {
+				rel, path, err = updater.InstallTag(opCtx, tag)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if installed != nil {
+				installed()
+			}
+			result := map[string]any{
+				"release": rel,
+				"binary":  path,
+			}
+			if restart {
+				restartIfActive(ctx, mgr, "mosdns", result)
+			}
+			return result, nil
+		})
+	})
+
+	mux.HandleFunc("/api/mosdns/config/download/stream", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w)
+			return
+		}
+		restart, _ := strconv.ParseBool(r.URL.Query().Get("restart"))
+		serveProgressStream(w, r, func(ctx context.Context) (any, error) {
+			opCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+			defer cancel()
+			status, err := downloadMosdnsConfig(opCtx, store, archiveURL)
+			if err != nil {
+				return nil, err
+			}
+			if restart {
+				restartIfActive(ctx, mgr, "mosdns", status)
+			}
+			return status, nil
+		})
+	})
+}
+
+// restartIfActive 在服务运行中时重启，并把 restarted/restartError/status 写入 result。
+func restartIfActive(ctx context.Context, mgr *service.Manager, name string, result map[string]any) {
+	ctx, cancel := context.WithTimeout(service.WithCause(ctx, service.CauseAPI), stopGracePeriod()+killWait+10*time.Second)
+	defer cancel()
+	if snap, err := mgr.Status(ctx, name); err == nil && snap.Status.Active() {
+		mosdns.ReportProgress(ctx, mosdns.Progress{Phase: mosdns.PhaseRestart, Message: name})
+		if err := mgr.Restart(ctx, name); err != nil {
+			result["restartError"] = err.Error()
+		} else {
+			result["restarted"] = true
+		}
+	}
+	if snap, err := mgr.Status(ctx, name); err == nil {
+		result["status"] = snap
+	}
+}
+
+// serveProgressStream 以 Server-Sent Events 执行 run 并推送过程：
+//   - progress：mosdns.Progress 阶段与下载字节数
+//   - log：本次操作写入的日志（经 logs.InfofContext/ErrorfContext 携带 ctx 中的操作标记），其他请求与后台任务的日志不会混入
+//   - done：run 的返回值；failed：{"error": "..."}（不用 error 作事件名，避免与 EventSource 的连接错误混淆）
+//
+// 客户端断开时 ctx 取消，run 随之中止。
+func serveProgressStream(w http.ResponseWriter, r *http.Request, run func(ctx context.Context) (any, error)) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondErr(w, errors.New("当前连接不支持流式输出"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// 关闭 nginx 等反向代理的缓冲，保证事件实时到达。
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	operation := fmt.Sprintf("stream-%d", streamSeq.Add(1))
+	ctx := logs.WithOperation(r.Context(), operation)
+	events := make(chan sseEvent, 64)
+	ctx = mosdns.WithProgress(ctx, func(p mosdns.Progress) {
+		select {
+		case events <- sseEvent{name: "progress", data: p}:
+		case <-r.Context().Done():
+		}
+	})
+	// 日志回调在写日志的 goroutine 中同步执行，通道满时丢弃，避免拖慢其他日志写入。
+	unsubscribe := logs.Subscribe(func(entry logs.Entry) {
+		if entry.Operation != operation {
+			return
+		}
+		entry.Operation = ""
+		select {
+		case events <- sseEvent{name: "log", data: entry}:
+		default:
+		}
+	})
+	defer unsubscribe()
+
+	type outcome struct {
+		result any
+		err    error
+	}
+	finished := make(chan outcome, 1)
+	go func() {
+		result, err := run(ctx)
+		finished <- outcome{result, err}
+	}()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case ev := <-events:
+			writeSSE(w, ev)
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case out := <-finished:
+			unsubscribe()
+			for drained := false; !drained; {
+				select {
+				case ev := <-events:
+					writeSSE(w, ev)
+				default:
+					drained = true
+				}
+			}
+			if out.err != nil {
+				writeSSE(w, sseEvent{name: "failed", data: map[string]string{"error": out.err.Error()}})
+			} else {
+				writeSSE(w, sseEvent{name: "done", data: out.result})
+			}
+			flusher.Flush()
+			return
+		}
+	}
+}
+
+func writeSSE(w http.ResponseWriter, ev sseEvent) {
+	data, err := json.Marshal(ev.data)
+	if err != nil {
+		data, _ = json.Marshal(map[string]string{"error": err.Error()})
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.name, data)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/herozmy/herobox/internal/logs"
+)
+
+func TestProgressStreamForwardsOnlyOwnLogs(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/mosdns/config/download/stream", nil)
+	serveProgressStream(rec, req, func(ctx context.Context) (any, error) {
+		logs.InfofContext(ctx, "[test] own line")
+		logs.Infof("[test] global line")
+		logs.InfofContext(logs.WithOperation(context.Background(), "other"), "[test] other operation")
+		return map[string]bool{"ok": true}, nil
+	})
+	body := rec.Body.String()
+	if !strings.Contains(body, "event: log\ndata: ") || !strings.Contains(body, "own line") {
+		t.Fatalf("own log line missing:\n%s", body)
+	}
+	for _, unwanted := range []string{"global line", "other operation", `"operation"`} {
+		if strings.Contains(body, unwanted) {
+			t.Fatalf("stream contains %q:\n%s", unwanted, body)
+		}
+	}
+	if !strings.Contains(body, "event: done\ndata: {\"ok\":true}") {
+		t.Fatalf("done event missing:\n%s", body)
+	}
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/herozmy/herobox/internal/config"
+	"github.com/herozmy/herobox/internal/logs"
+	"github.com/herozmy/herobox/internal/service"
+)
+
+// heroboxDataDir 返回 HeroBox 自身的数据目录（快照、事件等），默认与 herobox.yaml 同目录。
+func heroboxDataDir() string {
+	if dir := getenv("HEROBOX_DATA_DIR", ""); dir != "" {
+		return dir
+	}
+	return filepath.Dir(defaultConfigFile())
+}
+
+// safeRestartResult 描述一次安全重启的结果。
+type safeRestartResult struct {
+	Name       string            `json:"name"`
+	OK         bool              `json:"ok"`
+	ConfigDir  string            `json:"configDir"`
+	Window     string            `json:"window"`
+	RolledBack bool              `json:"rolledBack"`
+	Reason     string            `json:"reason,omitempty"`
+	Restored   []string          `json:"restored,omitempty"`
+	Untouched  []string          `json:"untouched,omitempty"`
+	Rejected   string            `json:"rejectedSnapshot,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Status     *service.Snapshot `json:"status,omitempty"`
+}
+
+// configSnapshots 管理每个服务“最近一次验证可用”的配置快照，位于 <数据目录>/snapshots/<服务名>/good。
+// 快照只包含配置编辑器允许的文本配置（yaml/json/txt 等），不会复制缓存与数据库文件。
+type configSnapshots struct {
+	root string
+	mu   sync.Mutex
+}
+
+func newConfigSnapshots(root string) *configSnapshots {
+	return &configSnapshots{root: root}
+}
+
+func (c *configSnapshots) goodDir(name string) string {
+	return filepath.Join(c.root, name, "good")
+}
+
+func (c *configSnapshots) hasGood(name string) bool {
+	info, err := os.Stat(c.goodDir(name))
+	return err == nil && info.IsDir()
+}
+
+// capture 将 configDir 中的配置文件复制到 <root>/<name>/<label>，替换已有的同名快照。
+func (c *configSnapshots) capture(name, label, configDir string) (string, error) {
+	target := filepath.Join(c.root, name, label)
+	staging := target + ".tmp"
+	_ = os.RemoveAll(staging)
+	if err := copyConfigTree(configDir, staging); err != nil {
+		_ = os.RemoveAll(staging)
+		return "", err
+	}
+	_ = os.RemoveAll(target)
+	if err := os.Rename(staging, target); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// SeedGood 在尚无已知可用快照时记录当前配置（用于 HeroBox 启动时服务已在正常运行的情况）。
+func (c *configSnapshots) SeedGood(name, configDir string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.hasGood(name) {
+		return
+	}
+	if _, err := c.capture(name, "good", configDir); err != nil {
+		logs.Errorf("[service] 记录 %s 配置快照失败: %v", name, err)
+	}
+}
+
+// promote 将 <root>/<name>/<from> 快照改名为 to，替换已有的同名快照。
+func (c *configSnapshots) promote(name, from, to string) (string, error) {
+	target := filepath.Join(c.root, name, to)
+	_ = os.RemoveAll(target)
+	if err := os.Rename(filepath.Join(c.root, name, from), target); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// isHeroboxDataPath 判断 path 是否为 HeroBox 数据目录或其中的快照、事件目录；
+// 数据目录位于服务配置目录之内时，遍历配置目录需要跳过它们，避免把快照复制进快照。
+func isHeroboxDataPath(path string) bool {
+	data, err := filepath.Abs(heroboxDataDir())
+	if err != nil {
+		return false
+	}
+	if path, err = filepath.Abs(path); err != nil {
+		return false
+	}
+	return path == data || path == filepath.Join(data, "snapshots") || path == filepath.Join(data, "events")
+}
+
+// copyConfigTree 复制 src 下允许编辑的配置文件，保留目录结构与权限，跳过其中的 HeroBox 数据目录。
+func copyConfigTree(src, dst string) error {
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		return err
+	}
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if d.IsDir() {
+			if isHeroboxDataPath(path) {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(filepath.Join(dst, rel), 0o755)
+		}
+		if !d.Type().IsRegular() || !isAllowedConfigFile(d.Name()) {
+			return nil
+		}
+		return copyFile(path, filepath.Join(dst, rel))
+	})
+}
+
+func copyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	mode := fs.FileMode(0o644)
+	if info, err := os.Stat(src); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, mode)
+}
+
+// restoreConfigTree 将快照中与当前内容不同或已被删除的文件写回 configDir，
+// 返回写回的文件与快照之后新增（保持不动）的文件，路径均相对 configDir。
+func restoreConfigTree(snapshot, configDir string) ([]string, []string, error) {
+	var restored []string
+	known := make(map[string]bool)
+	err := filepath.WalkDir(snapshot, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(snapshot, path)
+		if err != nil {
+			return err
+		}
+		known[rel] = true
+		want, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(configDir, rel)
+		if have, err := os.ReadFile(target); err == nil && bytes.Equal(have, want) {
+			return nil
+		}
+		if err := copyFile(path, target); err != nil {
+			return err
+		}
+		restored = append(restored, rel)
+		return nil
+	})
+	if err != nil {
+		return restored, nil, err
+	}
+	var untouched []string
+	_ = filepath.WalkDir(configDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != configDir && isHeroboxDataPath(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isAllowedConfigFile(d.Name()) {
+			return nil
+		}
+		if rel, err := filepath.Rel(configDir, path); err == nil && !known[rel] {
+			untouched = append(untouched, rel)
+		}
+		return nil
+	})
+	sort.Strings(restored)
+	sort.Strings(untouched)
+	return restored, untouched, nil
+}
+
+// serviceConfigDir 返回服务的配置目录：mosdns 来自 herobox.yaml，其余服务取启动参数中的 -c/-d/-C/-D。
+func serviceConfigDir(store *config.Store, spec service.ServiceSpec) string {
+	if spec.Name == "mosdns" {
+		return resolveConfigDir(store.GetConfigPath())
+	}
+	if spec.Args == nil {
+		return ""
+	}
+	hint := configHint(spec.Args())
+	if hint == "" {
+		return ""
+	}
+	return resolveConfigDir(hint)
+}
+
+// healthCheck 在 window 内每秒检查一次服务：进程必须始终在运行且未被看护重启（PID 与重启次数不变），
+// 声明了健康探测的服务（如 mosdns 的 DNS 查询）在窗口结束时还需探测通过。
+func healthCheck(ctx context.Context, mgr *service.Manager, name string, window time.Duration) error {
+	deadline := time.Now().Add(window)
+	var baseline *service.Snapshot
+	var last service.Snapshot
+	for {
+		snap, err := mgr.Status(ctx, name)
+		if err != nil {
+			return err
+		}
+		if !snap.Status.Active() {
+			reason := fmt.Sprintf("%s 状态为 %s", name, snap.Status)
+			if snap.LastExit != nil {
+				reason += "（" + describeExit(*snap.LastExit) + "）"
+			}
+			return errors.New(reason)
+		}
+		if baseline == nil {
+			baseline = &snap
+		} else if snap.Restarts > baseline.Restarts || (baseline.PID > 0 && snap.PID > 0 && snap.PID != baseline.PID) {
+			reason := fmt.Sprintf("%s 在健康检查期间崩溃并被重启 (PID %d → %d)", name, baseline.PID, snap.PID)
+			if snap.LastExit != nil {
+				reason += "，退出原因：" + describeExit(*snap.LastExit)
+			}
+			return errors.New(reason)
+		}
+		last = snap
+		if time.Now().After(deadline) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+	if last.Status == service.StatusDegraded && last.Health != nil {
+		return fmt.Errorf("%s 健康探测在 %s 内未通过：%s", name, window, last.Health.Reason)
+	}
+	return nil
+}
+
+// safeRestart 重启服务并在 window 内做健康检查；失败时把配置恢复为最近一次已知可用的快照并再次重启。
+// 重启前先把当前配置记录为 candidate 快照，通过检查后升级为 good，未通过时改名为 rejected，
+// 因此两者都是这次重启实际加载的配置，不受窗口期间的编辑影响。
+func safeRestart(ctx context.Context, mgr *service.Manager, store *config.Store, snapshots *configSnapshots, name string, window time.Duration) safeRestartResult {
+	result := safeRestartResult{Name: name, Window: window.String()}
+	spec, err := mgr.Spec(name)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Name = spec.Name
+	configDir := serviceConfigDir(store, spec)
+	if configDir == "" {
+		result.Error = fmt.Sprintf("无法确定 %s 的配置目录", spec.Name)
+		return result
+	}
+	result.ConfigDir = configDir
+
+	snapshots.mu.Lock()
+	defer snapshots.mu.Unlock()
+	hasGood := snapshots.hasGood(spec.Name)
+	if _, err := snapshots.capture(spec.Name, "candidate", configDir); err != nil {
+		result.Error = fmt.Sprintf("记录 %s 配置快照失败: %v", spec.Name, err)
+		return result
+	}
+
+	failure := mgr.Restart(ctx, spec.Name)
+	if failure == nil {
+		failure = healthCheck(ctx, mgr, spec.Name, window)
+	}
+	if failure == nil {
+		result.OK = true
+		if _, err := snapshots.promote(spec.Name, "candidate", "good"); err != nil {
+			logs.Errorf("[service] 记录 %s 配置快照失败: %v", spec.Name, err)
+		}
+		result.Status = statusPtr(ctx, mgr, spec.Name)
+		return result
+	}
+
+	result.Reason = failure.Error()
+	logs.Errorf("[service] %s 安全重启失败：%v", spec.Name, failure)
+	var checkErr *service.CheckError
+	if errors.As(failure, &checkErr) {
+		// 配置检查未通过时不会重启，原进程仍按旧配置运行，保留磁盘上的修改供用户更正。
+		_ = os.RemoveAll(filepath.Join(snapshots.root, spec.Name, "candidate"))
+		result.Error = failure.Error()
+		result.Status = statusPtr(ctx, mgr, spec.Name)
+		return result
+	}
+	if rejected, err := snapshots.promote(spec.Name, "candidate", "rejected"); err == nil {
+		result.Rejected = rejected
+	}
+	if !hasGood {
+		result.Error = "没有已知可用的配置快照，无法回滚"
+		result.Status = statusPtr(ctx, mgr, spec.Name)
+		return result
+	}
+	restored, untouched, err := restoreConfigTree(snapshots.goodDir(spec.Name), configDir)
+	result.Restored = restored
+	result.Untouched = untouched
+	if err != nil {
+		result.Error = fmt.Sprintf("恢复配置失败: %v", err)
+		result.Status = statusPtr(ctx, mgr, spec.Name)
+		return result
+	}
+	result.RolledBack = true
+	logs.Infof("[service] %s 已回滚配置 %v，正在重新启动", spec.Name, restored)
+	if err := mgr.Restart(service.WithCause(ctx, service.CauseRollback), spec.Name); err != nil {
+		result.Error = fmt.Sprintf("回滚后重启失败: %v", err)
+	} else if err := healthCheck(ctx, mgr, spec.Name, window); err != nil {
+		result.Error = fmt.Sprintf("回滚后健康检查仍未通过: %v", err)
+	}
+	result.Status = statusPtr(ctx, mgr, spec.Name)
+	return result
+}
+
+func statusPtr(ctx context.Context, mgr *service.Manager, name string) *service.Snapshot {
+	snap, err := mgr.Status(ctx, name)
+	if err != nil {
+		return nil
+	}
+	return &snap
+}
+
+// serveSafeRestart 处理 POST /api/services/{name}/safe-restart?window=15s。
+func serveSafeRestart(w http.ResponseWriter, r *http.Request, mgr *service.Manager, store *config.Store, snapshots *configSnapshots, name string) {
+	window := envDuration("HEROBOX_SAFE_RESTART_WINDOW", 10*time.Second)
+	if raw := strings.TrimSpace(r.URL.Query().Get("window")); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 || parsed > 5*time.Minute {
+			respondErr(w, fmt.Errorf("无效的 window 参数 %q", raw))
+			return
+		}
+		window = parsed
+	}
+	// 最坏情况下包含两次重启（各自可能等待停止宽限期）与两次健康检查。
+	timeout := 2 * (window + stopGracePeriod() + killWait + 15*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	result := safeRestart(ctx, mgr, store, snapshots, name, window)
+	if result.Status != nil && result.Name == "mosdns" {
+		updateMosdnsState(store, mosdnsBinaryPaths, *result.Status)
+		applyMosdnsVersion(store, result.Status)
+	}
+	respondJSON(w, result)
+}
+
+// seedConfigSnapshots 在 HeroBox 启动时为已在运行的服务记录初始快照。
+func seedConfigSnapshots(mgr *service.Manager, store *config.Store, snapshots *configSnapshots) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for _, snap := range mgr.List(ctx) {
+		if snap.Status != service.StatusRunning {
+			continue
+		}
+		spec, err := mgr.Spec(snap.Name)
+		if err != nil {
+			continue
+		}
+		if dir := serviceConfigDir(store, spec); dir != "" {
+			snapshots.SeedGood(spec.Name, dir)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/herozmy/herobox/internal/config"
+	"github.com/herozmy/herobox/internal/service"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestCopyConfigTreeSkipsNestedDataDir(t *testing.T) {
+	configDir := t.TempDir()
+	dataDir := filepath.Join(configDir, "herobox")
+	t.Setenv("HEROBOX_DATA_DIR", dataDir)
+	writeTestFile(t, filepath.Join(configDir, "config.yaml"), "log: {}\n")
+	writeTestFile(t, filepath.Join(configDir, "rules", "direct.txt"), "example.com\n")
+	writeTestFile(t, filepath.Join(dataDir, "snapshots", "mosdns", "good", "config.yaml"), "old\n")
+
+	dst := filepath.Join(t.TempDir(), "snapshot")
+	if err := copyConfigTree(configDir, dst); err != nil {
+		t.Fatal(err)
+	}
+	for _, rel := range []string{"config.yaml", filepath.Join("rules", "direct.txt")} {
+		if _, err := os.Stat(filepath.Join(dst, rel)); err != nil {
+			t.Errorf("%s not copied: %v", rel, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dst, "herobox")); !os.IsNotExist(err) {
+		t.Fatalf("data dir copied into snapshot: %v", err)
+	}
+
+	_, untouched, err := restoreConfigTree(dst, configDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(untouched) != 0 {
+		t.Fatalf("untouched = %v, want data dir ignored", untouched)
+	}
+}
+
+func TestSafeRestartSnapshotsConfigBeforeRestart(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HEROBOX_DATA_DIR", filepath.Join(dir, "data"))
+	configFile := filepath.Join(dir, "sing-box", "config.json")
+	writeTestFile(t, configFile, `{"v":1}`)
+	store, err := config.NewStore(filepath.Join(dir, "config.yaml"), filepath.Join(dir, "herobox.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim := service.NewSimulator()
+	mgr := service.NewManagerWithBackend([]service.ServiceSpec{{
+		Name:    "sing-box",
+		Unit:    "sing-box.service",
+		Backend: sim,
+		Args:    func() []string { return []string{"run", "-c", configFile} },
+	}}, sim)
+	snapshots := newConfigSnapshots(filepath.Join(heroboxDataDir(), "snapshots"))
+	ctx := context.Background()
+	if err := mgr.Start(ctx, "sing-box"); err != nil {
+		t.Fatal(err)
+	}
+
+	result := safeRestart(ctx, mgr, store, snapshots, "sing-box", 10*time.Millisecond)
+	if !result.OK {
+		t.Fatalf("first safe restart failed: %+v", result)
+	}
+	good := filepath.Join(snapshots.goodDir("sing-box"), "config.json")
+	if got := readTestFile(t, good); got != `{"v":1}` {
+		t.Fatalf("good snapshot = %s", got)
+	}
+	if _, err := os.Stat(filepath.Join(snapshots.root, "sing-box", "candidate")); !os.IsNotExist(err) {
+		t.Fatalf("candidate snapshot left behind: %v", err)
+	}
+
+	// 修改配置后重启失败：rejected 为重启前的配置，磁盘上恢复为已知可用版本。
+	writeTestFile(t, configFile, `{"v":2}`)
+	sim.FailNext("sing-box", "restart", errors.New("bad config"))
+	result = safeRestart(ctx, mgr, store, snapshots, "sing-box", 10*time.Millisecond)
+	if result.OK || !result.RolledBack || result.Error != "" {
+		t.Fatalf("second safe restart: %+v", result)
+	}
+	if got := readTestFile(t, filepath.Join(result.Rejected, "config.json")); got != `{"v":2}` {
+		t.Fatalf("rejected snapshot = %s", got)
+	}
+	if got := readTestFile(t, configFile); got != `{"v":1}` {
+		t.Fatalf("config after rollback = %s", got)
+	}
+}
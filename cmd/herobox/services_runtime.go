@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/herozmy/herobox/internal/config"
+	"github.com/herozmy/herobox/internal/service"
+)
+
+// allServicesName 是批量操作使用的伪服务名，服务名校验不允许下划线开头，不会与实际服务冲突。
+const allServicesName = "_all"
+
+// registryEntry 是 /api/service-registry 返回的服务定义，Builtin 标记内置核心。
+type registryEntry struct {
+	config.ServiceDefinition
+	Builtin bool `json:"builtin"`
+}
+
+// builtinServiceDefinitions 返回内置 mosdns / sing-box / mihomo 的默认定义，可通过环境变量调整。
+func builtinServiceDefinitions() []config.ServiceDefinition {
+	return []config.ServiceDefinition{
+		{
+			Name:        "mosdns",
+			Unit:        getenv("MOSDNS_UNIT", "mosdns.service"),
+			BinaryPaths: binaryCandidates("MOSDNS_BIN", "/usr/local/bin/mosdns"),
+			Description: "mosdns DNS forwarder",
+			// fake-ip 流量由 mosdns 转发给 sing-box / mihomo，批量启动时排在代理核心之后。
+			After: []string{"sing-box", "mihomo"},
+		},
+		{
+			Name:        "sing-box",
+			Unit:        getenv("SING_BOX_UNIT", "sing-box.service"),
+			BinaryPaths: binaryCandidates("SING_BOX_BIN", "/usr/local/bin/sing-box"),
+			Args:        singBoxArgs(),
+			Description: "sing-box service",
+		},
+		{
+			Name:        "mihomo",
+			Unit:        getenv("MIHOMO_UNIT", "mihomo.service"),
+			BinaryPaths: binaryCandidates("MIHOMO_BIN", "/usr/local/bin/mihomo"),
+			Args:        mihomoArgs(),
+			Description: "mihomo Daemon, Another Clash Kernel",
+		},
+	}
+}
+
+func isBuiltinService(name string) bool {
+	for _, def := range builtinServiceDefinitions() {
+		if def.Name == strings.ToLower(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// effectiveServiceDefinitions 合并内置定义与 herobox.yaml services 段：
+// 同名条目覆盖内置字段（非空字段生效），其余条目作为新增服务追加。
+func effectiveServiceDefinitions(store *config.Store) []config.ServiceDefinition {
+	defs := builtinServiceDefinitions()
+	for _, custom := range store.Services() {
+		idx := slices.IndexFunc(defs, func(d config.ServiceDefinition) bool { return d.Name == custom.Name })
+		if idx < 0 {
+			custom.Unit = custom.UnitName()
+			defs = append(defs, custom)
+			continue
+		}
+		defs[idx] = overlayServiceDefinition(defs[idx], custom)
+	}
+	return defs
+}
+
+func overlayServiceDefinition(base, override config.ServiceDefinition) config.ServiceDefinition {
+	// 显式写出的 unit 总是生效，即使与 <name>.service 相同，也会覆盖 MOSDNS_UNIT 等环境变量默认值。
+	if override.Unit != "" {
+		base.Unit = override.Unit
+	}
+	if len(override.BinaryPaths) > 0 {
+		base.BinaryPaths = override.BinaryPaths
+	}
+	if len(override.Args) > 0 {
+		base.Args = override.Args
+	}
+	if override.Mode != "" {
+		base.Mode = override.Mode
+	}
+	if override.Description != "" {
+		base.Description = override.Description
+	}
+	// 依赖列表以是否设置为准，after: [] 可清空内置依赖（例如不让 mosdns 等待代理核心）。
+	if override.After != nil {
+		base.After = override.After
+	}
+	if override.Requires != nil {
+		base.Requires = override.Requires
+	}
+	return base
+}
+
+func lookupServiceDefinition(store *config.Store, name string) (config.ServiceDefinition, bool) {
+	for _, def := range effectiveServiceDefinitions(store) {
+		if def.Name == strings.ToLower(name) {
+			return def, true
+		}
+	}
+	return config.ServiceDefinition{}, false
+}
+
+// simulatorBackend 供 mode: simulator 的服务共享，便于在没有真实核心的环境中演练。
+var simulatorBackend = service.NewSimulator()
+
+// serviceBackend 根据运行方式选择后端，auto 时返回 nil 以使用 Manager 的默认后端。
+func serviceBackend(def config.ServiceDefinition, store *config.Store, buildArgs func() []string) service.Backend {
+	switch def.Mode {
+	case config.ServiceModeExec:
+		return service.NewExecBackend(newExecHooks(store, def.Name, def.BinaryPaths, buildArgs))
+	case config.ServiceModeSystemd:
+		return service.SystemdBackend{}
+	case config.ServiceModeSimulator:
+		return simulatorBackend
+	}
+	if directExecEnabled() {
+		return service.NewExecBackend(newExecHooks(store, def.Name, def.BinaryPaths, buildArgs))
+	}
+	return nil
+}
+
+// isExecBackend 判断服务是否由 HeroBox 直接拉起（需要在启动时处理 autostart）。
+func isExecBackend(spec service.ServiceSpec) bool {
+	return spec.Backend != nil && spec.Backend.Name() == "exec"
+}
+
+// buildServiceSpec 将服务定义转换为 ServiceSpec。mosdns 默认使用专用驱动，启动参数由配置路径推导。
+// 启动前检查对直接拉起与 systemd 管理的服务都生效：mosdns 只解析配置文件，与启动方式无关；
+// sing-box/mihomo 在 systemd 下优先使用 unit 的 ExecStart 参数。模拟器不运行真实核心，不做检查。
+func buildServiceSpec(def config.ServiceDefinition, store *config.Store) service.ServiceSpec {
+	spec := service.ServiceSpec{
+		Name:        def.Name,
+		Unit:        def.Unit,
+		BinaryPaths: def.BinaryPaths,
+		Description: def.Description,
+		After:       def.After,
+		Requires:    def.Requires,
+	}
+	if def.Name == "mosdns" {
+		spec.Args = mosdnsArgs(store)
+		if dnsProbeEnabled() && def.Mode != config.ServiceModeSimulator {
+			spec.Health = newDNSHealth(store).Check
+		}
+		switch def.Mode {
+		case config.ServiceModeSystemd:
+			spec.Backend = service.SystemdBackend{}
+		case config.ServiceModeSimulator:
+			spec.Backend = simulatorBackend
+			return spec
+		default:
+			spec.Backend = service.NewExecBackend(newMosdnsHooks(store, def.BinaryPaths))
+		}
+		spec.Check = mosdnsCheck(store)
+		return spec
+	}
+	args := append([]string(nil), def.Args...)
+	spec.Args = func() []string { return append([]string(nil), args...) }
+	spec.Backend = serviceBackend(def, store, spec.Args)
+	if def.Mode == config.ServiceModeSimulator {
+		return spec
+	}
+	var buildCheckArgs func([]string) []string
+	switch def.Name {
+	case "sing-box":
+		buildCheckArgs = singBoxCheckArgs
+	case "mihomo":
+		buildCheckArgs = mihomoCheckArgs
+	default:
+		return spec
+	}
+	if isExecBackend(spec) {
+		spec.Check = commandCheck(buildCheckArgs)
+	} else {
+		spec.Check = unitCommandCheck(buildCheckArgs)
+	}
+	return spec
+}
+
+// serviceRegistryHandler 处理 /api/service-registry 的增删改查，变更会写入 herobox.yaml 并立即注册到 Manager。
+func serviceRegistryHandler(mgr *service.Manager, store *config.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/service-registry"), "/")
+		switch r.Method {
+		case http.MethodGet:
+			defs := effectiveServiceDefinitions(store)
+			entries := make([]registryEntry, 0, len(defs))
+			for _, def := range defs {
+				if name != "" && def.Name != strings.ToLower(name) {
+					continue
+				}
+				entries = append(entries, registryEntry{ServiceDefinition: def, Builtin: isBuiltinService(def.Name)})
+			}
+			if name != "" && len(entries) == 0 {
+				respondErr(w, fmt.Errorf("service %s 未注册", name))
+				return
+			}
+			respondJSON(w, entries)
+		case http.MethodPost, http.MethodPut:
+			var def config.ServiceDefinition
+			if err := json.NewDecoder(r.Body).Decode(&def); err != nil {
+				respondErr(w, fmt.Errorf("无效的请求体: %w", err))
+				return
+			}
+			if name != "" {
+				def.Name = name
+			}
+			def = def.Normalize()
+			if err := def.Validate(); err != nil {
+				respondErr(w, err)
+				return
+			}
+			if def.Name == "mosdns" && len(def.BinaryPaths) > 0 && !slices.Equal(def.BinaryPaths, mosdnsBinaryPaths) {
+				respondErr(w, errors.New("mosdns 二进制路径请通过 MOSDNS_BIN 配置"))
+				return
+			}
+			if err := store.UpsertService(def); err != nil {
+				respondErr(w, err)
+				return
+			}
+			effective, _ := lookupServiceDefinition(store, def.Name)
+			mgr.Register(buildServiceSpec(effective, store))
+			respondJSON(w, registryEntry{ServiceDefinition: effective, Builtin: isBuiltinService(effective.Name)})
+		case http.MethodDelete:
+			if name == "" {
+				respondErr(w, errors.New("缺少服务名称"))
+				return
+			}
+			if isBuiltinService(name) {
+				respondErr(w, fmt.Errorf("%s 为内置服务，无法删除", name))
+				return
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+			defer cancel()
+			snap, err := mgr.Status(ctx, name)
+			if err != nil {
+				respondErr(w, err)
+				return
+			}
+			if snap.Status.Active() {
+				respondErr(w, fmt.Errorf("%s 正在运行，请先停止", name))
+				return
+			}
+			if err := mgr.Unregister(name); err != nil {
+				respondErr(w, err)
+				return
+			}
+			releaseProcessSupervisor(name)
+			if _, err := store.RemoveService(name); err != nil {
+				respondErr(w, err)
+				return
+			}
+			respondJSON(w, map[string]any{"name": strings.ToLower(name), "removed": true})
+		default:
+			methodNotAllowed(w)
+		}
+	})
+}
+
+// serveServiceGroup 处理 POST /api/services/_all/{start|stop|restart}，按依赖顺序执行并返回每个服务的结果。
+func serveServiceGroup(w http.ResponseWriter, r *http.Request, mgr *service.Manager, store *config.Store, action string) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Minute)
+	defer cancel()
+	var (
+		results []service.GroupResult
+		err     error
+	)
+	switch action {
+	case "start":
+		results, err = mgr.StartAll(ctx)
+	case "stop":
+		results, err = mgr.StopAll(ctx)
+	case "restart":
+		results, err = mgr.RestartAll(ctx)
+	default:
+		respondErr(w, fmt.Errorf("不支持的批量操作 %s", action))
+		return
+	}
+	if err != nil {
+		respondErr(w, err)
+		return
+	}
+	failed := 0
+	for i := range results {
+		if !results[i].OK {
+			failed++
+		}
+		if results[i].Status != nil && results[i].Name == "mosdns" {
+			updateMosdnsState(store, mosdnsBinaryPaths, *results[i].Status)
+			applyMosdnsVersion(store, results[i].Status)
+		}
+	}
+	respondJSON(w, map[string]any{
+		"action":  action,
+		"ok":      failed == 0,
+		"failed":  failed,
+		"results": results,
+	})
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/herozmy/herobox/internal/config"
+)
+
+func TestOverlayClearsBuiltinAfter(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "herobox.yaml")
+	content := `services:
+  - name: mosdns
+    after: []
+  - name: sing-box
+    description: custom
+`
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := config.NewStore(filepath.Join(dir, "config.yaml"), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	afterOf := func(store *config.Store) config.Dependencies {
+		def, ok := lookupServiceDefinition(store, "mosdns")
+		if !ok {
+			t.Fatal("mosdns definition missing")
+		}
+		return def.After
+	}
+	if after := afterOf(store); after == nil || len(after) != 0 {
+		t.Fatalf("mosdns after = %#v, want cleared", after)
+	}
+
+	// 其他字段的修改写回后 after: [] 仍保留。
+	if err := store.UpsertService(config.ServiceDefinition{Name: "sing-box", Description: "changed"}); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := config.NewStore(filepath.Join(dir, "config.yaml"), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after := afterOf(reloaded); after == nil || len(after) != 0 {
+		t.Fatalf("mosdns after after reload = %#v, want cleared", after)
+	}
+
+	// 未设置 after 时沿用内置依赖。
+	empty, err := config.NewStore(filepath.Join(t.TempDir(), "config.yaml"), filepath.Join(t.TempDir(), "herobox.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after := afterOf(empty); !slices.Equal(after, config.Dependencies{"sing-box", "mihomo"}) {
+		t.Fatalf("builtin mosdns after = %#v", after)
+	}
+}
+
+func TestOverlayExplicitUnit(t *testing.T) {
+	t.Setenv("MOSDNS_UNIT", "mosdns-custom.service")
+	t.Setenv("MIHOMO_UNIT", "mihomo-custom.service")
+	dir := t.TempDir()
+	file := filepath.Join(dir, "herobox.yaml")
+	content := `services:
+  - name: mosdns
+    unit: mosdns.service
+  - name: mihomo
+    description: custom
+  - name: adguardhome
+    args: ["-w", "/opt/AdGuardHome"]
+`
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := config.NewStore(filepath.Join(dir, "config.yaml"), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unitOf := func(store *config.Store, name string) string {
+		def, ok := lookupServiceDefinition(store, name)
+		if !ok {
+			t.Fatalf("%s definition missing", name)
+		}
+		return def.Unit
+	}
+	// 显式写出的 unit 即使等于 <name>.service 也覆盖环境变量默认值。
+	if unit := unitOf(store, "mosdns"); unit != "mosdns.service" {
+		t.Fatalf("mosdns unit = %s, want mosdns.service", unit)
+	}
+	if unit := unitOf(store, "mihomo"); unit != "mihomo-custom.service" {
+		t.Fatalf("mihomo unit = %s, want mihomo-custom.service", unit)
+	}
+	if unit := unitOf(store, "adguardhome"); unit != "adguardhome.service" {
+		t.Fatalf("adguardhome unit = %s, want adguardhome.service", unit)
+	}
+
+	// 修改其他字段后保存，不会把默认 unit 写成显式值。
+	if err := store.UpsertService(config.ServiceDefinition{Name: "mihomo", Description: "changed"}); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := config.NewStore(filepath.Join(dir, "config.yaml"), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unit := unitOf(reloaded, "mihomo"); unit != "mihomo-custom.service" {
+		t.Fatalf("mihomo unit after save = %s, want mihomo-custom.service", unit)
+	}
+}
+
+func TestBuildServiceSpecCheckPerBackend(t *testing.T) {
+	dir := t.TempDir()
+	store, err := config.NewStore(filepath.Join(dir, "config.yaml"), filepath.Join(dir, "herobox.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name, mode string
+		backend    string
+		wantCheck  bool
+		directExec string
+	}{
+		{"mosdns", config.ServiceModeSystemd, "systemd", true, ""},
+		{"mosdns", config.ServiceModeSimulator, "simulator", false, ""},
+		{"mosdns", config.ServiceModeExec, "exec", true, ""},
+		{"sing-box", config.ServiceModeSystemd, "systemd", true, ""},
+		{"sing-box", config.ServiceModeSimulator, "simulator", false, ""},
+		{"sing-box", config.ServiceModeExec, "exec", true, ""},
+		{"mihomo", config.ServiceModeSystemd, "systemd", true, ""},
+		{"mihomo", "", "exec", true, "true"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name+"/"+tc.backend, func(t *testing.T) {
+			t.Setenv("HEROBOX_DIRECT_EXEC", tc.directExec)
+			def, _ := lookupServiceDefinition(store, tc.name)
+			def.Mode = tc.mode
+			spec := buildServiceSpec(def, store)
+			if spec.Backend == nil || spec.Backend.Name() != tc.backend {
+				t.Fatalf("backend = %v, want %s", spec.Backend, tc.backend)
+			}
+			if got := spec.Check != nil; got != tc.wantCheck {
+				t.Fatalf("check attached = %v, want %v", got, tc.wantCheck)
+			}
+		})
+	}
+}
+
+func TestParseExecStart(t *testing.T) {
+	value := "{ path=/usr/local/bin/sing-box ; argv[]=/usr/local/bin/sing-box run -c /etc/sing-box/config.json -D /var/lib/sing-box ; ignore_errors=no ; start_time=[n/a] ; stop_time=[n/a] ; pid=0 ; code=(null) ; status=0/0 }"
+	path, args, ok := parseExecStart(value)
+	if !ok || path != "/usr/local/bin/sing-box" || !slices.Equal(args, []string{"run", "-c", "/etc/sing-box/config.json", "-D", "/var/lib/sing-box"}) {
+		t.Fatalf("parseExecStart = %q, %q, %v", path, args, ok)
+	}
+	if got := singBoxCheckArgs(args); got[0] != "check" || got[2] != "/etc/sing-box/config.json" {
+		t.Fatalf("check args = %q", got)
+	}
+	if _, _, ok := parseExecStart(""); ok {
+		t.Fatal("empty ExecStart parsed")
+	}
+}
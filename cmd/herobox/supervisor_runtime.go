@@ -0,0 +1,386 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/herozmy/herobox/internal/config"
+	"github.com/herozmy/herobox/internal/logs"
+	"github.com/herozmy/herobox/internal/service"
+)
+
+// restartPolicy 控制崩溃后的自动重启节奏。
+type restartPolicy struct {
+	MaxCrashes int           // Window 内允许的最大崩溃次数，超过后放弃重启
+	Window     time.Duration // 崩溃计数窗口
+	BaseDelay  time.Duration // 首次重启等待时间，之后指数翻倍
+	MaxDelay   time.Duration // 单次等待上限
+}
+
+func defaultRestartPolicy() restartPolicy {
+	return restartPolicy{
+		MaxCrashes: envInt("HEROBOX_WATCHDOG_MAX_CRASHES", 5),
+		Window:     envDuration("HEROBOX_WATCHDOG_WINDOW", 10*time.Minute),
+		BaseDelay:  envDuration("HEROBOX_WATCHDOG_BASE_DELAY", time.Second),
+		MaxDelay:   envDuration("HEROBOX_WATCHDOG_MAX_DELAY", time.Minute),
+	}
+}
+
+func (p restartPolicy) delay(attempt int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+// processSupervisor 以子进程方式运行核心，并在非预期退出时按 restartPolicy 自动拉起。
+type processSupervisor struct {
+	name   string
+	store  *config.Store
+	policy restartPolicy
+	launch func(out io.Writer) (*exec.Cmd, error)
+	// output 在首次拉起时才打开，只由 HeroBox 直接执行的服务才会创建输出目录。
+	output io.Writer
+
+	mu         sync.Mutex
+	cmd        *exec.Cmd
+	startedAt  time.Time
+	exited     chan struct{}
+	generation int
+	cancel     chan struct{}
+	restarts   int
+	crashes    []time.Time
+	lastExit   *service.ExitInfo
+	state      string
+}
+
+// supervisors 按服务名复用看护器，服务定义被替换时仍能追踪已启动的子进程。
+var supervisors = struct {
+	sync.Mutex
+	byName map[string]*processSupervisor
+}{byName: make(map[string]*processSupervisor)}
+
+// newProcessSupervisor 返回服务对应的看护器；同名看护器已存在时仅更新启动函数。
+func newProcessSupervisor(name string, store *config.Store, launch func(out io.Writer) (*exec.Cmd, error)) *processSupervisor {
+	supervisors.Lock()
+	defer supervisors.Unlock()
+	key := strings.ToLower(name)
+	if existing, ok := supervisors.byName[key]; ok {
+		existing.mu.Lock()
+		existing.launch = launch
+		existing.mu.Unlock()
+		return existing
+	}
+	s := &processSupervisor{
+		name:   name,
+		store:  store,
+		policy: defaultRestartPolicy(),
+		launch: launch,
+	}
+	supervisors.byName[key] = s
+	return s
+}
+
+// releaseProcessSupervisor 在服务被移除时取消看护。
+func releaseProcessSupervisor(name string) {
+	supervisors.Lock()
+	defer supervisors.Unlock()
+	key := strings.ToLower(name)
+	if s, ok := supervisors.byName[key]; ok {
+		s.Disarm()
+		s.mu.Lock()
+		output := s.output
+		s.mu.Unlock()
+		if file, ok := output.(*logs.RotatingFile); ok {
+			file.Close()
+		}
+		delete(supervisors.byName, key)
+	}
+}
+
+// Start 启动进程并开始看护；手动启动会重置崩溃计数。
+func (s *processSupervisor) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.runningLocked() {
+		return fmt.Errorf("%s 已在运行 (PID %d)", s.name, s.cmd.Process.Pid)
+	}
+	s.stopBackoffLocked()
+	s.crashes = nil
+	return s.spawnLocked()
+}
+
+// Stop 标记为预期退出并发送 SIGTERM，watch 协程不会再重启。进程在 HEROBOX_STOP_GRACE
+// 内未退出时发送 SIGKILL；只有确认进程已退出才返回 nil。
+func (s *processSupervisor) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	s.generation++
+	s.stopBackoffLocked()
+	s.state = ""
+	if !s.runningLocked() {
+		s.mu.Unlock()
+		return errors.New("进程未由 herobox 启动")
+	}
+	proc := s.cmd.Process
+	exited := s.exited
+	s.mu.Unlock()
+
+	if err := proc.Signal(syscall.SIGTERM); err != nil && !isClosed(exited) {
+		return err
+	}
+	grace := stopGracePeriod()
+	if waitClosed(ctx, exited, grace) {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("等待 %s 退出时中断: %w", s.name, err)
+	}
+	logs.Errorf("[watchdog] %s (PID %d) 未在 %s 内退出，发送 SIGKILL", s.name, proc.Pid, grace)
+	_ = proc.Kill()
+	if waitClosed(ctx, exited, killWait) {
+		return nil
+	}
+	return fmt.Errorf("%s (PID %d) 在 SIGKILL 后仍未退出", s.name, proc.Pid)
+}
+
+// Disarm 取消处于退避等待中的自动重启，返回是否确有待执行的重启。
+func (s *processSupervisor) Disarm() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pending := s.cancel != nil
+	s.generation++
+	s.stopBackoffLocked()
+	s.state = ""
+	return pending
+}
+
+// Restart 停止当前子进程，确认其退出后重新拉起。
+func (s *processSupervisor) Restart(ctx context.Context) error {
+	if s.Running() {
+		if err := s.Stop(ctx); err != nil {
+			return err
+		}
+	}
+	return s.Start()
+}
+
+// Running 报告受管子进程是否存活。
+func (s *processSupervisor) Running() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.runningLocked()
+}
+
+// Annotate 将 PID、运行时长、重启次数与最近一次退出信息写入快照。
+func (s *processSupervisor) Annotate(snap *service.Snapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snap.Restarts = s.restarts
+	if s.runningLocked() {
+		since := s.startedAt
+		snap.PID = s.cmd.Process.Pid
+		snap.ActiveSince = &since
+		snap.UptimeSeconds = int64(time.Since(since).Seconds())
+	}
+	if s.lastExit != nil {
+		exit := *s.lastExit
+		snap.LastExit = &exit
+	}
+	snap.Watchdog = s.state
+}
+
+func (s *processSupervisor) runningLocked() bool {
+	if s.cmd == nil || s.exited == nil {
+		return false
+	}
+	select {
+	case <-s.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// waitClosed 等待 ch 关闭，超时或 ctx 结束时返回 false。
+func waitClosed(ctx context.Context, ch <-chan struct{}, timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-ch:
+		return true
+	case <-ctx.Done():
+		return isClosed(ch)
+	case <-timer.C:
+		return isClosed(ch)
+	}
+}
+
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *processSupervisor) stopBackoffLocked() {
+	if s.cancel != nil {
+		close(s.cancel)
+		s.cancel = nil
+	}
+}
+
+func (s *processSupervisor) spawnLocked() error {
+	if s.output == nil {
+		s.output = openServiceOutput(s.name)
+	}
+	cmd, err := s.launch(s.output)
+	if err != nil {
+		fmt.Fprintf(s.output, "%s [herobox] 启动 %s 失败: %v\n", time.Now().Format(outputTimeLayout), s.name, err)
+		return err
+	}
+	fmt.Fprintf(s.output, "%s [herobox] 启动 %s (PID %d)\n", time.Now().Format(outputTimeLayout), s.name, cmd.Process.Pid)
+	s.generation++
+	s.cmd = cmd
+	s.startedAt = time.Now()
+	s.exited = make(chan struct{})
+	s.state = "watching"
+	if err := s.store.SetServicePID(s.name, cmd.Process.Pid); err != nil {
+		logs.Errorf("[watchdog] 记录 %s PID 失败: %v", s.name, err)
+	}
+	go s.watch(cmd, s.exited, s.generation)
+	return nil
+}
+
+func (s *processSupervisor) watch(cmd *exec.Cmd, exited chan struct{}, generation int) {
+	waitErr := cmd.Wait()
+	exit := exitInfo(cmd, waitErr)
+
+	fmt.Fprintf(s.output, "%s [herobox] %s (PID %d) 退出: %s\n", exit.Time.Format(outputTimeLayout), s.name, cmd.Process.Pid, describeExit(exit))
+
+	s.mu.Lock()
+	close(exited)
+	s.lastExit = &exit
+	if generation != s.generation {
+		// 预期内的退出（stop/restart），不触发看护逻辑。
+		s.mu.Unlock()
+		return
+	}
+	_ = s.store.SetServicePID(s.name, 0)
+	serviceEvents.Record(service.Event{
+		Service: s.name,
+		Kind:    service.EventCrash,
+		Cause:   service.CauseWatchdog,
+		From:    service.StatusRunning,
+		To:      service.StatusStopped,
+		PID:     cmd.Process.Pid,
+		Error:   describeExit(exit),
+	})
+	now := time.Now()
+	s.crashes = append(s.crashes, now)
+	recent := s.crashes[:0]
+	for _, t := range s.crashes {
+		if now.Sub(t) <= s.policy.Window {
+			recent = append(recent, t)
+		}
+	}
+	s.crashes = recent
+	if len(s.crashes) > s.policy.MaxCrashes {
+		s.state = "gave-up"
+		s.mu.Unlock()
+		logs.Errorf("[watchdog] %s 在 %s 内崩溃 %d 次，放弃自动重启（%s）", s.name, s.policy.Window, len(recent), describeExit(exit))
+		serviceEvents.Record(service.Event{
+			Service: s.name,
+			Kind:    service.EventGaveUp,
+			Cause:   service.CauseWatchdog,
+			Detail:  fmt.Sprintf("%s 内崩溃 %d 次", s.policy.Window, len(recent)),
+		})
+		return
+	}
+	delay := s.policy.delay(len(s.crashes))
+	cancel := make(chan struct{})
+	s.cancel = cancel
+	s.state = "backoff"
+	s.mu.Unlock()
+
+	logs.Errorf("[watchdog] %s 意外退出（%s），%s 后重启", s.name, describeExit(exit), delay)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-cancel:
+		return
+	case <-timer.C:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if generation != s.generation || s.cancel != cancel {
+		return
+	}
+	s.cancel = nil
+	ev := service.Event{Service: s.name, Kind: service.EventRestart, Action: "restart", Cause: service.CauseWatchdog}
+	if err := s.spawnLocked(); err != nil {
+		s.state = "gave-up"
+		logs.Errorf("[watchdog] 重启 %s 失败: %v", s.name, err)
+		ev.Error = err.Error()
+		serviceEvents.Record(ev)
+		return
+	}
+	s.restarts++
+	logs.Infof("[watchdog] %s 已自动重启 (PID %d，累计 %d 次)", s.name, s.cmd.Process.Pid, s.restarts)
+	ev.From, ev.To, ev.PID = service.StatusStopped, service.StatusRunning, s.cmd.Process.Pid
+	ev.Detail = fmt.Sprintf("累计自动重启 %d 次", s.restarts)
+	serviceEvents.Record(ev)
+}
+
+func exitInfo(cmd *exec.Cmd, waitErr error) service.ExitInfo {
+	info := service.ExitInfo{Time: time.Now(), Code: -1}
+	if cmd.ProcessState == nil {
+		if waitErr != nil {
+			info.Error = waitErr.Error()
+		}
+		return info
+	}
+	info.Code = cmd.ProcessState.ExitCode()
+	if status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		info.Signal = status.Signal().String()
+	}
+	return info
+}
+
+func describeExit(exit service.ExitInfo) string {
+	if exit.Signal != "" {
+		return "signal " + exit.Signal
+	}
+	if exit.Error != "" {
+		return exit.Error
+	}
+	return "exit code " + strconv.Itoa(exit.Code)
+}
+
+func envInt(key string, fallback int) int {
+	if val, err := strconv.Atoi(getenv(key, "")); err == nil && val > 0 {
+		return val
+	}
+	return fallback
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	if val, err := time.ParseDuration(getenv(key, "")); err == nil && val > 0 {
+		return val
+	}
+	return fallback
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/herozmy/herobox/internal/logs"
+	"github.com/herozmy/herobox/internal/service"
+)
+
+// unitTemplate 是 HeroBox 生成的 systemd unit 模板，三个核心共用。
+var unitTemplate = template.Must(template.New("unit").Parse(`# 由 HeroBox 生成，重新安装会覆盖本文件。
+[Unit]
+Description={{.Description}}
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=simple
+ExecStart={{.ExecStart}}
+Restart=on-failure
+RestartSec=5s
+LimitNOFILE=1048576
+
+[Install]
+WantedBy=multi-user.target
+`))
+
+// unitPlan 描述一次 unit 预览/安装的结果。
+type unitPlan struct {
+	Unit      string `json:"unit"`
+	Path      string `json:"path"`
+	Content   string `json:"content"`
+	Exists    bool   `json:"exists"`
+	Existing  string `json:"existing,omitempty"`
+	Changed   bool   `json:"changed"`
+	Diff      string `json:"diff,omitempty"`
+	Installed bool   `json:"installed"`
+	Reloaded  bool   `json:"reloaded"`
+}
+
+func systemdUnitDir() string {
+	return getenv("HEROBOX_SYSTEMD_DIR", "/etc/systemd/system")
+}
+
+// renderUnit 使用 spec 的二进制路径与启动参数渲染 unit 文件内容。
+func renderUnit(spec service.ServiceSpec) (string, error) {
+	if spec.Args == nil {
+		return "", fmt.Errorf("%s 未定义启动参数，无法生成 unit", spec.Name)
+	}
+	binary, err := firstExistingBinary(spec.BinaryPaths)
+	if err != nil {
+		binary = primaryBinary(spec.BinaryPaths)
+	}
+	if binary == "" {
+		return "", fmt.Errorf("%s 未配置二进制路径", spec.Name)
+	}
+	parts := append([]string{binary}, spec.Args()...)
+	for i, part := range parts {
+		parts[i] = quoteUnitArg(part)
+	}
+	description := spec.Description
+	if description == "" {
+		description = spec.Name + " (managed by HeroBox)"
+	}
+	description = strings.ReplaceAll(description, "%", "%%")
+	var buf bytes.Buffer
+	if err := unitTemplate.Execute(&buf, map[string]string{
+		"Description": description,
+		"ExecStart":   strings.Join(parts, " "),
+	}); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// quoteUnitArg 按 systemd 的命令行规则为含空白或引号的参数加引号，
+// 并把 % 转义为 %%，避免被当作 unit 说明符展开。
+func quoteUnitArg(arg string) string {
+	arg = strings.ReplaceAll(arg, "%", "%%")
+	if arg != "" && !strings.ContainsAny(arg, " \t\"'\\") {
+		return arg
+	}
+	escaped := strings.ReplaceAll(arg, `\`, `\\`)
+	escaped = strings.ReplaceAll(escaped, `"`, `\"`)
+	return `"` + escaped + `"`
+}
+
+// planUnit 渲染 unit 并与已存在的文件比较。
+func planUnit(spec service.ServiceSpec) (unitPlan, error) {
+	unit := strings.TrimSpace(spec.Unit)
+	if unit == "" || strings.ContainsAny(unit, `/\`) || !strings.HasSuffix(unit, ".service") {
+		return unitPlan{}, fmt.Errorf("无效的 unit 名称 %q", spec.Unit)
+	}
+	content, err := renderUnit(spec)
+	if err != nil {
+		return unitPlan{}, err
+	}
+	plan := unitPlan{
+		Unit:    unit,
+		Path:    filepath.Join(systemdUnitDir(), unit),
+		Content: content,
+		Changed: true,
+	}
+	existing, err := os.ReadFile(plan.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return plan, nil
+		}
+		return unitPlan{}, err
+	}
+	plan.Exists = true
+	plan.Existing = string(existing)
+	plan.Changed = plan.Existing != content
+	if plan.Changed {
+		plan.Diff = lineDiff(plan.Existing, content)
+	}
+	return plan, nil
+}
+
+// installUnit 原子写入 unit 文件并执行 systemctl daemon-reload。
+func installUnit(ctx context.Context, spec service.ServiceSpec) (unitPlan, error) {
+	plan, err := planUnit(spec)
+	if err != nil {
+		return plan, err
+	}
+	if !plan.Changed {
+		return plan, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(plan.Path), 0o755); err != nil {
+		return plan, err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(plan.Path), "."+plan.Unit+"-*")
+	if err != nil {
+		return plan, err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.WriteString(plan.Content); err != nil {
+		temp.Close()
+		return plan, err
+	}
+	if err := temp.Close(); err != nil {
+		return plan, err
+	}
+	if err := os.Chmod(temp.Name(), 0o644); err != nil {
+		return plan, err
+	}
+	if err := os.Rename(temp.Name(), plan.Path); err != nil {
+		return plan, err
+	}
+	plan.Installed = true
+	logs.Infof("[service] 已写入 %s unit -> %s", spec.Name, plan.Path)
+	if _, err := exec.LookPath("systemctl"); err != nil {
+		return plan, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, "systemctl", "daemon-reload").CombinedOutput()
+	if err != nil {
+		msg := strings.TrimSpace(string(output))
+		if msg == "" {
+			msg = err.Error()
+		}
+		return plan, fmt.Errorf("systemctl daemon-reload 失败: %s", msg)
+	}
+	plan.Reloaded = true
+	return plan, nil
+}
+
+// serveServiceUnit 处理 GET（预览）与 PUT（安装）/api/services/{name}/unit。
+func serveServiceUnit(w http.ResponseWriter, r *http.Request, mgr *service.Manager, name string) {
+	spec, err := mgr.Spec(name)
+	if err != nil {
+		respondErr(w, err)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		plan, err := planUnit(spec)
+		if err != nil {
+			respondErr(w, err)
+			return
+		}
+		respondJSON(w, plan)
+	case http.MethodPut:
+		plan, err := installUnit(r.Context(), spec)
+		if err != nil {
+			respondErr(w, err)
+			return
+		}
+		respondJSON(w, plan)
+	default:
+		methodNotAllowed(w)
+	}
+}
+
+// lineDiff 基于最长公共子序列输出逐行差异，"-" 为旧内容，"+" 为新内容。
+func lineDiff(oldText, newText string) string {
+	a := strings.Split(strings.TrimSuffix(oldText, "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(newText, "\n"), "\n")
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var buf strings.Builder
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			buf.WriteString("  " + a[i] + "\n")
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			buf.WriteString("- " + a[i] + "\n")
+			i++
+		default:
+			buf.WriteString("+ " + b[j] + "\n")
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		buf.WriteString("- " + a[i] + "\n")
+	}
+	for ; j < len(b); j++ {
+		buf.WriteString("+ " + b[j] + "\n")
+	}
+	return buf.String()
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/herozmy/herobox/internal/service"
+)
+
+func TestQuoteUnitArg(t *testing.T) {
+	cases := map[string]string{
+		"/usr/local/bin/mosdns": "/usr/local/bin/mosdns",
+		"/opt/my tools/mosdns":  `"/opt/my tools/mosdns"`,
+		"/opt/100%/mosdns":      "/opt/100%%/mosdns",
+		"/opt/50% off/mosdns":   `"/opt/50%% off/mosdns"`,
+		`say "hi"`:              `"say \"hi\""`,
+		"":                      `""`,
+	}
+	for arg, want := range cases {
+		if got := quoteUnitArg(arg); got != want {
+			t.Errorf("quoteUnitArg(%q) = %s, want %s", arg, got, want)
+		}
+	}
+}
+
+func TestPlanUnitEscapesPaths(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HEROBOX_SYSTEMD_DIR", dir)
+	existing := `# 由 HeroBox 生成，重新安装会覆盖本文件。
+[Unit]
+Description=mosdns (managed by HeroBox)
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=simple
+ExecStart=/usr/local/bin/mosdns start -d /etc/mosdns
+Restart=on-failure
+RestartSec=5s
+LimitNOFILE=1048576
+
+[Install]
+WantedBy=multi-user.target
+`
+	if err := os.WriteFile(filepath.Join(dir, "mosdns.service"), []byte(existing), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	spec := service.ServiceSpec{
+		Name:        "mosdns",
+		Unit:        "mosdns.service",
+		BinaryPaths: []string{filepath.Join(dir, "100%", "mosdns")},
+		Args: func() []string {
+			return []string{"start", "-d", "/etc/mos dns"}
+		},
+	}
+	plan, err := planUnit(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.Exists || !plan.Changed {
+		t.Fatalf("plan = %+v, want an existing, changed unit", plan)
+	}
+	wantExec := "ExecStart=" + filepath.Join(dir, "100%%", "mosdns") + ` start -d "/etc/mos dns"`
+	wantDiff := "- ExecStart=/usr/local/bin/mosdns start -d /etc/mosdns\n+ " + wantExec + "\n"
+	if !strings.Contains(plan.Diff, wantDiff) {
+		t.Fatalf("diff missing escaped ExecStart:\n%s", plan.Diff)
+	}
+	for _, line := range strings.Split(plan.Diff, "\n") {
+		if strings.HasPrefix(line, "- ") || strings.HasPrefix(line, "+ ") {
+			if !strings.Contains(line, "ExecStart=") {
+				t.Fatalf("unexpected changed line %q in diff:\n%s", line, plan.Diff)
+			}
+		}
+	}
+}
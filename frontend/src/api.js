@@ -50,6 +50,26 @@ export async function apiRequest(path, options = {}) {
 
 // Specific API functions
 export const getServiceStatus = () => apiRequest('/api/services/mosdns');
+export const getServiceOutput = (name, lines = 400) => apiRequest(`/api/services/${name}/output?lines=${lines}`);
+export const getServiceJournal = (name, params = {}) => {
+  const query = new URLSearchParams(params).toString();
+  return apiRequest(`/api/services/${name}/journal${query ? `?${query}` : ''}`);
+};
+export const getServiceEvents = (name, params = {}) => {
+  const query = new URLSearchParams(params).toString();
+  return apiRequest(`/api/services/${name}/events${query ? `?${query}` : ''}`);
+};
+export const getServiceMetrics = (name, window = '1h') => apiRequest(`/api/services/${name}/metrics?window=${window}`);
+export const previewServiceUnit = (name) => apiRequest(`/api/services/${name}/unit`);
+export const installServiceUnit = (name) => apiRequest(`/api/services/${name}/unit`, { method: 'PUT' });
+export const safeRestartService = (name, window = '10s') => apiRequest(`/api/services/${name}/safe-restart?window=${window}`, { method: 'POST' });
+export const runServiceGroup = (action) => apiRequest(`/api/services/_all/${action}`, { method: 'POST' });
+export const listServiceRegistry = () => apiRequest('/api/service-registry');
+export const saveServiceDefinition = (definition) => apiRequest(`/api/service-registry/${definition.name}`, {
+  method: 'PUT',
+  body: JSON.stringify(definition),
+});
+export const removeServiceDefinition = (name) => apiRequest(`/api/service-registry/${name}`, { method: 'DELETE' });
 export const getMosdnsLogs = () => apiRequest('/api/mosdns/logs');
 export const getMosdnsConfigStatus = () => apiRequest('/api/mosdns/config');
 export const getSettings = () => apiRequest('/api/settings');
@@ -60,9 +80,62 @@ export const saveSettings = (settings) => apiRequest('/api/settings', {
 export const startMosdns = () => apiRequest('/api/services/mosdns/start', { method: 'POST' });
 export const stopMosdns = () => apiRequest('/api/services/mosdns/stop', { method: 'POST' });
 export const restartMosdns = () => apiRequest('/api/services/mosdns/restart', { method: 'POST' });
+export const enableMosdns = () => apiRequest('/api/services/mosdns/enable', { method: 'POST' });
+export const disableMosdns = () => apiRequest('/api/services/mosdns/disable', { method: 'POST' });
 export const getLatestMosdnsKernel = () => apiRequest('/api/mosdns/kernel/latest');
 export const updateMosdnsKernel = () => apiRequest('/api/mosdns/kernel/update', { method: 'POST' });
+export const listMosdnsKernelReleases = (page = 1, perPage = 10) =>
+  apiRequest(`/api/mosdns/kernel/releases?page=${page}&perPage=${perPage}`);
+export const installMosdnsKernel = (tag) =>
+  apiRequest(`/api/mosdns/kernel/install?tag=${encodeURIComponent(tag)}`, { method: 'POST' });
+export const listMosdnsKernelBackups = () => apiRequest('/api/mosdns/kernel/backups');
+export const rollbackMosdnsKernel = (version, restart = false) =>
+  apiRequest(`/api/mosdns/kernel/rollback?version=${encodeURIComponent(version)}&restart=${restart}`, {
+    method: 'POST',
+  });
+export const getLatestSingBoxKernel = () => apiRequest('/api/sing-box/kernel/latest');
+export const updateSingBoxKernel = () => apiRequest('/api/sing-box/kernel/update', { method: 'POST' });
+export const getLatestMihomoKernel = () => apiRequest('/api/mihomo/kernel/latest');
+export const updateMihomoKernel = () => apiRequest('/api/mihomo/kernel/update', { method: 'POST' });
 export const downloadMosdnsConfig = () => apiRequest('/api/mosdns/config/download', { method: 'POST' });
+
+// streamTask 通过 SSE 执行长耗时操作：onProgress 接收阶段与下载字节数，onLog 接收期间的日志，
+// 返回的 Promise 在 done 时兑现、failed 或连接中断时拒绝；close() 可提前断开并中止操作。
+export function streamTask(path, { onProgress, onLog } = {}) {
+  const source = new EventSource(`${API_BASE_URL}${path}`);
+  const parse = (event) => {
+    try {
+      return JSON.parse(event.data);
+    } catch (err) {
+      return null;
+    }
+  };
+  const promise = new Promise((resolve, reject) => {
+    source.addEventListener('progress', (event) => onProgress && onProgress(parse(event)));
+    source.addEventListener('log', (event) => onLog && onLog(parse(event)));
+    source.addEventListener('done', (event) => {
+      source.close();
+      resolve(parse(event));
+    });
+    source.addEventListener('failed', (event) => {
+      source.close();
+      const payload = parse(event);
+      reject(new Error((payload && payload.error) || '操作失败'));
+    });
+    source.onerror = () => {
+      // 服务端在 done/failed 后关闭连接，EventSource 会尝试重连，这里直接结束避免重复执行。
+      source.close();
+      reject(new Error('连接已断开'));
+    };
+  });
+  promise.close = () => source.close();
+  return promise;
+}
+
+export const streamMosdnsKernelUpdate = ({ tag = '', restart = false } = {}, handlers) =>
+  streamTask(`/api/mosdns/kernel/update/stream?tag=${encodeURIComponent(tag)}&restart=${restart}`, handlers);
+export const streamMosdnsConfigDownload = ({ restart = false } = {}, handlers) =>
+  streamTask(`/api/mosdns/config/download/stream?restart=${restart}`, handlers);
 export const updateConfigPath = (path) => apiRequest('/api/mosdns/config', {
   method: 'PUT',
   body: JSON.stringify({ path }),
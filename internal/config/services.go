@@ -0,0 +1,209 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// 服务运行方式。
+const (
+	ServiceModeAuto      = ""          // 有 systemd 时使用 systemctl，否则直接执行
+	ServiceModeSystemd   = "systemd"   // 始终通过 systemctl 控制
+	ServiceModeExec      = "exec"      // 始终由 HeroBox 直接拉起
+	ServiceModeSimulator = "simulator" // 使用内存模拟器，不会真正启动进程
+)
+
+var serviceNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// ServiceDefinition 描述 herobox.yaml services 段中的一个受控服务。
+type ServiceDefinition struct {
+	Name        string   `yaml:"name" json:"name"`
+	Unit        string   `yaml:"unit,omitempty" json:"unit,omitempty"`
+	BinaryPaths []string `yaml:"binaryPaths,omitempty" json:"binaryPaths,omitempty"`
+	// Args 为直接执行时追加在二进制之后的启动参数。
+	Args        []string `yaml:"args,omitempty" json:"args,omitempty"`
+	Mode        string   `yaml:"mode,omitempty" json:"mode,omitempty"`
+	Description string   `yaml:"description,omitempty" json:"description,omitempty"`
+	// After / Requires 决定批量启动顺序，Requires 的依赖失败时跳过本服务。
+	// 覆盖内置服务时，显式写 after: [] 可清空内置的依赖。
+	After    Dependencies `yaml:"after,omitempty" json:"after,omitzero"`
+	Requires Dependencies `yaml:"requires,omitempty" json:"requires,omitzero"`
+}
+
+// Dependencies 为服务依赖列表，区分未设置（nil）与显式清空（空列表），
+// 空列表在保存时会写成 []，以便覆盖内置定义。
+type Dependencies []string
+
+// IsZero 仅在未设置时为真，供 yaml omitempty 与 json omitzero 判断。
+func (d Dependencies) IsZero() bool {
+	return d == nil
+}
+
+// Normalize 清理空白。unit 留空表示未设置，保存时不会写入默认值，
+// 以便区分显式写出的 unit 与内置/环境变量提供的默认值。
+func (d ServiceDefinition) Normalize() ServiceDefinition {
+	d.Name = strings.ToLower(strings.TrimSpace(d.Name))
+	d.Unit = strings.TrimSpace(d.Unit)
+	d.Mode = strings.ToLower(strings.TrimSpace(d.Mode))
+	if d.Mode == "auto" {
+		d.Mode = ServiceModeAuto
+	}
+	d.Description = strings.TrimSpace(d.Description)
+	d.BinaryPaths = compactStrings(d.BinaryPaths)
+	d.Args = compactStrings(d.Args)
+	d.After = normalizeDependencies(d.After)
+	d.Requires = normalizeDependencies(d.Requires)
+	return d
+}
+
+// UnitName 返回 unit 名称，未设置时为 <name>.service。
+func (d ServiceDefinition) UnitName() string {
+	if d.Unit != "" {
+		return d.Unit
+	}
+	return d.Name + ".service"
+}
+
+// Validate 校验名称、unit 与运行方式。
+func (d ServiceDefinition) Validate() error {
+	if d.Name == "" {
+		return errors.New("服务名称不能为空")
+	}
+	if !serviceNamePattern.MatchString(d.Name) {
+		return fmt.Errorf("服务名称 %q 仅支持小写字母、数字、点、下划线与连字符", d.Name)
+	}
+	if strings.ContainsAny(d.Unit, `/\`) {
+		return fmt.Errorf("无效的 unit 名称 %q", d.Unit)
+	}
+	switch d.Mode {
+	case ServiceModeAuto, ServiceModeSystemd, ServiceModeExec, ServiceModeSimulator:
+	default:
+		return fmt.Errorf("不支持的运行方式 %q", d.Mode)
+	}
+	for _, dep := range append(append([]string(nil), d.After...), d.Requires...) {
+		if dep == d.Name {
+			return fmt.Errorf("服务 %s 不能依赖自身", d.Name)
+		}
+	}
+	return nil
+}
+
+// Clone 返回深拷贝。
+func (d ServiceDefinition) Clone() ServiceDefinition {
+	clone := d
+	clone.BinaryPaths = append([]string(nil), d.BinaryPaths...)
+	clone.Args = append([]string(nil), d.Args...)
+	clone.After = cloneDependencies(d.After)
+	clone.Requires = cloneDependencies(d.Requires)
+	return clone
+}
+
+// Services 返回 herobox.yaml 中声明的服务列表。
+func (s *Store) Services() []ServiceDefinition {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]ServiceDefinition, len(s.services))
+	for i, def := range s.services {
+		out[i] = def.Clone()
+	}
+	return out
+}
+
+// UpsertService 新增或替换同名服务定义。
+func (s *Store) UpsertService(def ServiceDefinition) error {
+	def = def.Normalize()
+	if err := def.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	replaced := false
+	for i, existing := range s.services {
+		if existing.Name == def.Name {
+			s.services[i] = def.Clone()
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		s.services = append(s.services, def.Clone())
+	}
+	s.dropInvalidServiceLocked(def.Name)
+	s.mu.Unlock()
+	return s.persist()
+}
+
+// RemoveService 删除服务定义，返回是否存在。
+func (s *Store) RemoveService(name string) (bool, error) {
+	key := strings.ToLower(strings.TrimSpace(name))
+	s.mu.Lock()
+	found := false
+	for i, existing := range s.services {
+		if existing.Name == key {
+			s.services = append(s.services[:i], s.services[i+1:]...)
+			found = true
+			break
+		}
+	}
+	if s.dropInvalidServiceLocked(key) {
+		found = true
+	}
+	s.mu.Unlock()
+	if !found {
+		return false, nil
+	}
+	return true, s.persist()
+}
+
+// dropInvalidServiceLocked 删除同名的无效条目（被新定义替换或被删除），返回是否存在。
+func (s *Store) dropInvalidServiceLocked(name string) bool {
+	found := false
+	kept := s.invalidServices[:0]
+	for _, def := range s.invalidServices {
+		if strings.EqualFold(strings.TrimSpace(def.Name), name) {
+			found = true
+			continue
+		}
+		kept = append(kept, def)
+	}
+	s.invalidServices = kept
+	return found
+}
+
+func normalizeDependencies(deps Dependencies) Dependencies {
+	if deps == nil {
+		return nil
+	}
+	if out := lowerStrings(compactStrings(deps)); out != nil {
+		return out
+	}
+	return Dependencies{}
+}
+
+func cloneDependencies(deps Dependencies) Dependencies {
+	if deps == nil {
+		return nil
+	}
+	return append(Dependencies{}, deps...)
+}
+
+func compactStrings(values []string) []string {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if trimmed := strings.TrimSpace(v); trimmed != "" {
+			out = append(out, trimmed)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+func lowerStrings(values []string) []string {
+	for i, v := range values {
+		values[i] = strings.ToLower(v)
+	}
+	return values
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadSkipsInvalidServices(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "herobox.yaml")
+	content := `services:
+  - name: adguard
+    unit: adguardhome.service
+  - name: Bad Name
+    mode: exec
+  - name: broken
+    mode: docker
+`
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewStore(filepath.Join(dir, "config.yaml"), file)
+	if err != nil {
+		t.Fatalf("NewStore failed on invalid services entry: %v", err)
+	}
+	services := store.Services()
+	if len(services) != 1 || services[0].Name != "adguard" {
+		t.Fatalf("services = %+v, want only adguard", services)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Bad Name", "broken"} {
+		if !strings.Contains(string(data), name) {
+			t.Fatalf("invalid entry %q was dropped from herobox.yaml:\n%s", name, data)
+		}
+	}
+
+	// 用合法定义替换无效条目后，旧条目不再写回。
+	if err := store.UpsertService(ServiceDefinition{Name: "broken", Mode: ServiceModeExec}); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(file)
+	if strings.Contains(string(data), "docker") {
+		t.Fatalf("replaced invalid entry still persisted:\n%s", data)
+	}
+	if found, err := store.RemoveService("bad name"); err != nil || !found {
+		t.Fatalf("RemoveService(bad name) = %v, %v", found, err)
+	}
+	data, _ = os.ReadFile(file)
+	if strings.Contains(string(data), "Bad Name") {
+		t.Fatalf("removed invalid entry still persisted:\n%s", data)
+	}
+}
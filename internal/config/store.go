@@ -8,6 +8,8 @@ import (
 	"sync"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/herozmy/herobox/internal/logs"
 )
 
 // Store 持久化保存可在前端调整的配置信息，例如 mosdns 配置路径与 UI 设置。
@@ -18,6 +20,11 @@ type Store struct {
 	mosdnsState     string
 	mosdnsPID       int
 	mosdnsVersion   string
+	mosdnsAutostart bool
+	processes       map[string]processState
+	services        []ServiceDefinition
+	// invalidServices 为加载时校验失败的条目，不参与管理，但保存时原样写回，避免用户配置丢失。
+	invalidServices []ServiceDefinition
 	uiSettings      map[string]string
 	configOverrides Overrides
 	filePath        string
@@ -27,14 +34,24 @@ type fileState struct {
 	HeroboxPort     string            `yaml:"heroboxPort"`
 	UISettings      map[string]string `yaml:"uiSettings,omitempty"`
 	ConfigOverrides Overrides         `yaml:"configOverrides,omitempty"`
-	Mosdns          struct {
+	// Processes 记录直接执行模式下各服务（mosdns 除外）的进程信息与开机自启标记。
+	Processes map[string]processState `yaml:"processes,omitempty"`
+	// Services 声明额外受控服务，或覆盖内置 mosdns/sing-box/mihomo 的字段。
+	Services []ServiceDefinition `yaml:"services,omitempty"`
+	Mosdns   struct {
 		ConfigPath string `yaml:"configPath"`
 		Status     string `yaml:"status"`
 		PID        int    `yaml:"pid"`
 		Version    string `yaml:"version"`
+		Autostart  bool   `yaml:"autostart"`
 	} `yaml:"mosdns"`
 }
 
+type processState struct {
+	PID       int  `yaml:"pid,omitempty"`
+	Autostart bool `yaml:"autostart,omitempty"`
+}
+
 func NewStore(defaultConfigPath, filePath string) (*Store, error) {
 	if defaultConfigPath == "" {
 		defaultConfigPath = "/etc/herobox/mosdns/config.yaml"
@@ -42,6 +59,7 @@ func NewStore(defaultConfigPath, filePath string) (*Store, error) {
 	store := &Store{
 		configPath: defaultConfigPath,
 		uiSettings: make(map[string]string),
+		processes:  make(map[string]processState),
 		filePath:   filePath,
 	}
 	if err := store.load(); err != nil {
@@ -91,6 +109,73 @@ func (s *Store) MosdnsPID() int {
 	return s.mosdnsPID
 }
 
+// ServicePID 返回直接执行模式下记录的进程 PID，mosdns 沿用 mosdns.pid 字段。
+func (s *Store) ServicePID(name string) int {
+	key := strings.ToLower(strings.TrimSpace(name))
+	if key == "mosdns" {
+		return s.MosdnsPID()
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.processes[key].PID
+}
+
+// SetServicePID 记录服务 PID，pid <= 0 时清除记录。
+func (s *Store) SetServicePID(name string, pid int) error {
+	key := strings.ToLower(strings.TrimSpace(name))
+	if key == "" {
+		return errors.New("服务名称不能为空")
+	}
+	if key == "mosdns" {
+		return s.SetMosdnsPID(pid)
+	}
+	s.mu.Lock()
+	s.setProcessLocked(key, func(p *processState) { p.PID = max(pid, 0) })
+	s.mu.Unlock()
+	return s.persist()
+}
+
+// Autostart 返回 HeroBox 启动时是否需要自动拉起该服务（仅直接执行模式）。
+func (s *Store) Autostart(name string) bool {
+	key := strings.ToLower(strings.TrimSpace(name))
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if key == "mosdns" {
+		return s.mosdnsAutostart
+	}
+	return s.processes[key].Autostart
+}
+
+// SetAutostart 记录服务的开机自启标记。
+func (s *Store) SetAutostart(name string, enabled bool) error {
+	key := strings.ToLower(strings.TrimSpace(name))
+	if key == "" {
+		return errors.New("服务名称不能为空")
+	}
+	s.mu.Lock()
+	if key == "mosdns" {
+		s.mosdnsAutostart = enabled
+	} else {
+		s.setProcessLocked(key, func(p *processState) { p.Autostart = enabled })
+	}
+	s.mu.Unlock()
+	return s.persist()
+}
+
+// setProcessLocked 修改进程记录，字段全部为零值时删除该条目。
+func (s *Store) setProcessLocked(key string, update func(p *processState)) {
+	if s.processes == nil {
+		s.processes = make(map[string]processState)
+	}
+	proc := s.processes[key]
+	update(&proc)
+	if proc == (processState{}) {
+		delete(s.processes, key)
+		return
+	}
+	s.processes[key] = proc
+}
+
 func (s *Store) SetMosdnsVersion(version string) error {
 	version = strings.TrimSpace(version)
 	s.mu.Lock()
@@ -189,6 +274,26 @@ func (s *Store) load() error {
 	if state.HeroboxPort != "" {
 		s.heroboxPort = state.HeroboxPort
 	}
+	s.mosdnsAutostart = state.Mosdns.Autostart
+	s.services = s.services[:0]
+	s.invalidServices = nil
+	for _, raw := range state.Services {
+		def := raw.Normalize()
+		if err := def.Validate(); err != nil {
+			logs.Errorf("[config] 忽略 services 中无效的条目 %q: %v", raw.Name, err)
+			s.invalidServices = append(s.invalidServices, raw.Clone())
+			continue
+		}
+		s.services = append(s.services, def)
+	}
+	if len(state.Processes) > 0 {
+		if s.processes == nil {
+			s.processes = make(map[string]processState)
+		}
+		for name, proc := range state.Processes {
+			s.processes[strings.ToLower(name)] = proc
+		}
+	}
 	s.configOverrides = state.ConfigOverrides.Clone()
 	return nil
 }
@@ -214,6 +319,19 @@ func (s *Store) persist() error {
 	state.Mosdns.PID = s.mosdnsPID
 	state.Mosdns.Version = s.mosdnsVersion
 	state.ConfigOverrides = s.configOverrides.Clone()
+	state.Mosdns.Autostart = s.mosdnsAutostart
+	for _, def := range s.services {
+		state.Services = append(state.Services, def.Clone())
+	}
+	for _, def := range s.invalidServices {
+		state.Services = append(state.Services, def.Clone())
+	}
+	if len(s.processes) > 0 {
+		state.Processes = make(map[string]processState, len(s.processes))
+		for name, proc := range s.processes {
+			state.Processes[name] = proc
+		}
+	}
 	s.mu.RUnlock()
 
 	data, err := yaml.Marshal(&state)
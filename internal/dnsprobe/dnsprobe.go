@@ -0,0 +1,170 @@
+// Package dnsprobe 使用标准库构造 DNS 报文，对本地 DNS 监听地址发起 UDP/TCP 查询。
+package dnsprobe
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// 常用的查询类型。
+const (
+	TypeA    uint16 = 1
+	TypeAAAA uint16 = 28
+)
+
+var rcodeNames = map[int]string{
+	0: "NOERROR",
+	1: "FORMERR",
+	2: "SERVFAIL",
+	3: "NXDOMAIN",
+	4: "NOTIMP",
+	5: "REFUSED",
+}
+
+// Result 是一次查询的结果。
+type Result struct {
+	Address   string    `json:"address"`
+	Network   string    `json:"network"`
+	Name      string    `json:"name"`
+	LatencyMS float64   `json:"latencyMs"`
+	Rcode     string    `json:"rcode,omitempty"`
+	Answers   int       `json:"answers"`
+	Truncated bool      `json:"truncated,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// OK 表示服务器给出了有效应答（NOERROR 或 NXDOMAIN 均说明解析链路正常）。
+func (r Result) OK() bool {
+	return r.Error == "" && (r.Rcode == "NOERROR" || r.Rcode == "NXDOMAIN")
+}
+
+// Query 向 address 发送一次查询，network 为 "udp" 或 "tcp"。
+func Query(ctx context.Context, network, address, name string, qtype uint16) Result {
+	result := Result{Address: address, Network: network, Name: name, Time: time.Now()}
+	msg, id, err := buildQuery(name, qtype)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	start := time.Now()
+	resp, err := exchange(ctx, network, address, msg)
+	result.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	header, err := parseHeader(resp, id)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Rcode = header.rcode
+	result.Answers = header.answers
+	result.Truncated = header.truncated
+	return result
+}
+
+func exchange(ctx context.Context, network, address string, msg []byte) ([]byte, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		framed := make([]byte, 2+len(msg))
+		binary.BigEndian.PutUint16(framed, uint16(len(msg)))
+		copy(framed[2:], msg)
+		if _, err := conn.Write(framed); err != nil {
+			return nil, err
+		}
+		var size [2]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return nil, err
+		}
+		resp := make([]byte, binary.BigEndian.Uint16(size[:]))
+		if _, err := io.ReadFull(conn, resp); err != nil {
+			return nil, err
+		}
+		return resp, nil
+	default:
+		if _, err := conn.Write(msg); err != nil {
+			return nil, err
+		}
+		buf := make([]byte, 4096)
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
+
+// buildQuery 构造带 RD 标志的单问题查询报文。
+func buildQuery(name string, qtype uint16) ([]byte, uint16, error) {
+	var idBytes [2]byte
+	if _, err := rand.Read(idBytes[:]); err != nil {
+		return nil, 0, err
+	}
+	id := binary.BigEndian.Uint16(idBytes[:])
+	msg := make([]byte, 12, 12+len(name)+6)
+	binary.BigEndian.PutUint16(msg[0:], id)
+	binary.BigEndian.PutUint16(msg[2:], 0x0100) // RD
+	binary.BigEndian.PutUint16(msg[4:], 1)      // QDCOUNT
+	name = strings.TrimSuffix(strings.TrimSpace(name), ".")
+	if name == "" {
+		return nil, 0, errors.New("查询域名为空")
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 {
+			return nil, 0, fmt.Errorf("无效的域名 %q", name)
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0)
+	msg = binary.BigEndian.AppendUint16(msg, qtype)
+	msg = binary.BigEndian.AppendUint16(msg, 1) // IN
+	return msg, id, nil
+}
+
+type header struct {
+	rcode     string
+	answers   int
+	truncated bool
+}
+
+func parseHeader(resp []byte, id uint16) (header, error) {
+	if len(resp) < 12 {
+		return header{}, fmt.Errorf("应答过短 (%d 字节)", len(resp))
+	}
+	if got := binary.BigEndian.Uint16(resp[0:]); got != id {
+		return header{}, fmt.Errorf("应答 ID 不匹配 (%d != %d)", got, id)
+	}
+	flags := binary.BigEndian.Uint16(resp[2:])
+	if flags&0x8000 == 0 {
+		return header{}, errors.New("收到的报文不是应答")
+	}
+	code := int(flags & 0x000f)
+	name, ok := rcodeNames[code]
+	if !ok {
+		name = fmt.Sprintf("RCODE%d", code)
+	}
+	return header{
+		rcode:     name,
+		answers:   int(binary.BigEndian.Uint16(resp[6:])),
+		truncated: flags&0x0200 != 0,
+	}, nil
+}
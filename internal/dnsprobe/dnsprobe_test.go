@@ -0,0 +1,193 @@
+package dnsprobe
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildQuery(t *testing.T) {
+	msg, id, err := buildQuery(" www.Example.com. ", TypeAAAA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := binary.BigEndian.Uint16(msg[0:]); got != id {
+		t.Fatalf("ID = %d, want %d", got, id)
+	}
+	if flags := binary.BigEndian.Uint16(msg[2:]); flags != 0x0100 {
+		t.Fatalf("flags = %#04x, want RD only", flags)
+	}
+	counts := msg[4:12]
+	if !bytes.Equal(counts, []byte{0, 1, 0, 0, 0, 0, 0, 0}) {
+		t.Fatalf("counts = %v, want QDCOUNT=1", counts)
+	}
+	question := append([]byte("\x03www\x07Example\x03com\x00"), 0, 28, 0, 1)
+	if !bytes.Equal(msg[12:], question) {
+		t.Fatalf("question = %q, want %q", msg[12:], question)
+	}
+
+	for _, name := range []string{"", ".", "a..b", strings.Repeat("x", 64) + ".com"} {
+		if _, _, err := buildQuery(name, TypeA); err == nil {
+			t.Errorf("buildQuery(%q) accepted an invalid name", name)
+		}
+	}
+}
+
+// reply 按 query 构造应答：复制 ID 与问题，置 QR/RD/RA 标志与 rcode。
+func reply(query []byte, rcode uint16, answers uint16, truncated bool) []byte {
+	resp := append([]byte(nil), query...)
+	flags := uint16(0x8180) | rcode
+	if truncated {
+		flags |= 0x0200
+	}
+	binary.BigEndian.PutUint16(resp[2:], flags)
+	binary.BigEndian.PutUint16(resp[6:], answers)
+	return resp
+}
+
+func TestParseHeader(t *testing.T) {
+	query, id, err := buildQuery("example.com", TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name    string
+		resp    []byte
+		rcode   string
+		answers int
+		trunc   bool
+		wantErr string
+	}{
+		{"noerror", reply(query, 0, 2, false), "NOERROR", 2, false, ""},
+		{"nxdomain", reply(query, 3, 0, false), "NXDOMAIN", 0, false, ""},
+		{"servfail", reply(query, 2, 0, false), "SERVFAIL", 0, false, ""},
+		{"unknown rcode", reply(query, 9, 0, false), "RCODE9", 0, false, ""},
+		{"truncated flag", reply(query, 0, 0, true), "NOERROR", 0, true, ""},
+		{"short", reply(query, 0, 1, false)[:11], "", 0, false, "应答过短"},
+		{"id mismatch", func() []byte {
+			resp := reply(query, 0, 1, false)
+			binary.BigEndian.PutUint16(resp, id+1)
+			return resp
+		}(), "", 0, false, "ID 不匹配"},
+		{"not a response", query, "", 0, false, "不是应答"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h, err := parseHeader(tc.resp, id)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if h.rcode != tc.rcode || h.answers != tc.answers || h.truncated != tc.trunc {
+				t.Fatalf("header = %+v", h)
+			}
+		})
+	}
+}
+
+// serveUDP 在 127.0.0.1 上应答 UDP 查询，每次应答前等待 delay。
+func serveUDP(t *testing.T, delay time.Duration, rcode uint16) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			time.Sleep(delay)
+			_, _ = pc.WriteTo(reply(buf[:n], rcode, 1, false), addr)
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+// serveTCP 在 127.0.0.1 上按两字节长度前缀应答 TCP 查询。
+func serveTCP(t *testing.T, delay time.Duration, rcode uint16) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				var size [2]byte
+				if _, err := io.ReadFull(conn, size[:]); err != nil {
+					return
+				}
+				query := make([]byte, binary.BigEndian.Uint16(size[:]))
+				if _, err := io.ReadFull(conn, query); err != nil {
+					return
+				}
+				time.Sleep(delay)
+				resp := reply(query, rcode, 1, false)
+				_, _ = conn.Write(binary.BigEndian.AppendUint16(nil, uint16(len(resp))))
+				_, _ = conn.Write(resp)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestQueryRoundTrip(t *testing.T) {
+	const delay = 30 * time.Millisecond
+	cases := []struct {
+		network string
+		address string
+		rcode   string
+		ok      bool
+	}{
+		{"udp", serveUDP(t, delay, 0), "NOERROR", true},
+		{"tcp", serveTCP(t, delay, 0), "NOERROR", true},
+		{"udp", serveUDP(t, delay, 3), "NXDOMAIN", true},
+		{"tcp", serveTCP(t, delay, 2), "SERVFAIL", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.network+"/"+tc.rcode, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			result := Query(ctx, tc.network, tc.address, "example.com", TypeA)
+			if result.Error != "" {
+				t.Fatalf("query failed: %s", result.Error)
+			}
+			if result.Rcode != tc.rcode || result.Answers != 1 || result.OK() != tc.ok {
+				t.Fatalf("result = %+v", result)
+			}
+			if result.LatencyMS < float64(delay.Milliseconds()) {
+				t.Fatalf("latency %.1fms shorter than responder delay %s", result.LatencyMS, delay)
+			}
+		})
+	}
+}
+
+func TestQueryTimeout(t *testing.T) {
+	address := serveUDP(t, time.Second, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	result := Query(ctx, "udp", address, "example.com", TypeA)
+	if result.Error == "" || result.OK() {
+		t.Fatalf("result = %+v, want timeout error", result)
+	}
+}
@@ -1,6 +1,7 @@
 package logs
 
 import (
+    "context"
     "fmt"
     "log"
 )
@@ -23,19 +24,30 @@ func BufferEntries() []Entry {
 }
 
 func Infof(format string, args ...any) {
-    write("info", format, args...)
+    write("", "info", format, args...)
 }
 
 func Errorf(format string, args ...any) {
-    write("error", format, args...)
+    write("", "error", format, args...)
 }
 
-func write(level, format string, args ...any) {
+// InfofContext 与 Infof 相同，并为日志带上 ctx 中的操作标记。
+func InfofContext(ctx context.Context, format string, args ...any) {
+    write(OperationFrom(ctx), "info", format, args...)
+}
+
+// ErrorfContext 与 Errorf 相同，并为日志带上 ctx 中的操作标记。
+func ErrorfContext(ctx context.Context, format string, args ...any) {
+    write(OperationFrom(ctx), "error", format, args...)
+}
+
+func write(operation, level, format string, args ...any) {
 	msg := formatMessage(format, args...)
 	log.Print(msg)
     if defaultBuffer != nil {
         defaultBuffer.Add(level, msg)
     }
+    publish(operation, level, msg)
 }
 
 func formatMessage(format string, args ...any) string {
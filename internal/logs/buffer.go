@@ -7,9 +7,11 @@ import (
 
 // Entry 表示一条日志。
 type Entry struct {
-	Timestamp time.Time `json:"timestamp"`
+	Timestamp time.Time `json:"timestamp,omitzero"`
 	Message   string    `json:"message"`
 	Level     string    `json:"level"`
+	// Operation 为写日志时 ctx 中的操作标记，只在订阅回调中填写。
+	Operation string `json:"operation,omitempty"`
 }
 
 // Buffer 是一个线程安全的环形日志缓冲器。
@@ -5,7 +5,10 @@ import "context"
 // context key.
 type contextKey string
 
-const bufferKey contextKey = "herobox.logbuffer"
+const (
+	bufferKey    contextKey = "herobox.logbuffer"
+	operationKey contextKey = "herobox.operation"
+)
 
 // WithBuffer 在 ctx 中注入日志缓冲器。
 func WithBuffer(ctx context.Context, buf *Buffer) context.Context {
@@ -19,3 +22,17 @@ func FromContext(ctx context.Context) *Buffer {
 	}
 	return nil
 }
+
+// WithOperation 在 ctx 中标记所属操作，经 InfofContext/ErrorfContext 写入的日志会带上该标记。
+func WithOperation(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, operationKey, id)
+}
+
+// OperationFrom 返回 ctx 中的操作标记，未标记时为空。
+func OperationFrom(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(operationKey).(string)
+	return id
+}
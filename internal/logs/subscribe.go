@@ -0,0 +1,40 @@
+package logs
+
+import (
+	"sync"
+	"time"
+)
+
+var (
+	subscribersMu  sync.RWMutex
+	subscribers    = map[int]func(Entry){}
+	nextSubscriber int
+)
+
+// Subscribe 注册日志回调，之后写入的每条日志都会同步调用 fn，返回的 cancel 用于取消订阅。
+// 经 InfofContext/ErrorfContext 写入的日志在 Entry.Operation 中带有操作标记，可据此只关注某次操作。
+// fn 在写日志的 goroutine 中执行，应尽快返回且不能再写日志。
+func Subscribe(fn func(Entry)) (cancel func()) {
+	subscribersMu.Lock()
+	id := nextSubscriber
+	nextSubscriber++
+	subscribers[id] = fn
+	subscribersMu.Unlock()
+	return func() {
+		subscribersMu.Lock()
+		delete(subscribers, id)
+		subscribersMu.Unlock()
+	}
+}
+
+func publish(operation, level, msg string) {
+	subscribersMu.RLock()
+	defer subscribersMu.RUnlock()
+	if len(subscribers) == 0 {
+		return
+	}
+	entry := Entry{Timestamp: time.Now(), Level: level, Message: msg, Operation: operation}
+	for _, fn := range subscribers {
+		fn(entry)
+	}
+}
@@ -0,0 +1,334 @@
+package mosdns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/herozmy/herobox/internal/logs"
+)
+
+// BinaryInfo 记录内核二进制的版本与来源。
+type BinaryInfo struct {
+	Version     string    `json:"version"`
+	SourceTag   string    `json:"sourceTag,omitempty"`
+	InstalledAt time.Time `json:"installedAt,omitempty"`
+}
+
+// Backup 为一个被替换下来的二进制，File 与安装目标位于同一目录。
+type Backup struct {
+	BinaryInfo
+	File       string    `json:"file"`
+	Size       int64     `json:"size"`
+	ReplacedAt time.Time `json:"replacedAt"`
+}
+
+type backupManifest struct {
+	Current *BinaryInfo `json:"current,omitempty"`
+	Backups []Backup    `json:"backups"`
+}
+
+// Backups 在替换内核前把旧二进制保留为 <binary>.<version>.bak，
+// 元数据写在同目录的 .<binary>.backups.json，最多保留 Keep 个（默认 3）。
+type Backups struct {
+	Keep int
+	// Version 返回指定二进制的版本号，检测失败时使用记录中的版本。
+	Version func(path string) (string, error)
+
+	mu sync.Mutex
+}
+
+// List 返回 target 的备份（最新替换的在前）与当前二进制的记录。
+func (b *Backups) List(target string) ([]Backup, *BinaryInfo, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	manifest, err := b.load(target)
+	if err != nil {
+		return nil, nil, err
+	}
+	return manifest.Backups, manifest.Current, nil
+}
+
+// Preserve 在替换 target 前保留当前二进制，返回的 undo 用于安装失败时撤销本次备份；
+// target 不存在时不做任何事。
+func (b *Backups) Preserve(ctx context.Context, target string) (undo func(), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	undoLocked, err := b.preserveLocked(ctx, target)
+	if err != nil || undoLocked == nil {
+		return nil, err
+	}
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		undoLocked()
+	}, nil
+}
+
+// Installed 在新二进制写入 target 后记录其版本与来源 tag。
+func (b *Backups) Installed(ctx context.Context, target, tag string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	manifest, err := b.load(target)
+	if err != nil {
+		logs.ErrorfContext(ctx, "[%s] 读取备份记录失败: %v", filepath.Base(target), err)
+		manifest = &backupManifest{}
+	}
+	manifest.Current = &BinaryInfo{Version: b.version(target, ""), SourceTag: tag, InstalledAt: time.Now()}
+	b.prune(target, manifest)
+	if err := b.save(target, manifest); err != nil {
+		logs.ErrorfContext(ctx, "[%s] 写入备份记录失败: %v", filepath.Base(target), err)
+	}
+}
+
+// Restore 保留当前二进制后，以原子 rename 把 version 对应的备份切换为 target。
+func (b *Backups) Restore(ctx context.Context, target, version string) (*Backup, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	manifest, err := b.load(target)
+	if err != nil {
+		return nil, err
+	}
+	var chosen *Backup
+	for i := range manifest.Backups {
+		if manifest.Backups[i].Version == version || manifest.Backups[i].File == version {
+			backup := manifest.Backups[i]
+			chosen = &backup
+			break
+		}
+	}
+	if chosen == nil {
+		return nil, fmt.Errorf("未找到版本 %s 的备份", version)
+	}
+	dir := filepath.Dir(target)
+	src, err := os.Open(filepath.Join(dir, chosen.File))
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	// 先复制到同目录的临时文件，保留备份本身，再 rename 覆盖 target。
+	temp, err := os.CreateTemp(dir, "."+filepath.Base(target)+"-restore-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := io.Copy(temp, src); err != nil {
+		temp.Close()
+		return nil, err
+	}
+	if err := temp.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(temp.Name(), 0o755); err != nil {
+		return nil, err
+	}
+	undo, err := b.preserveLocked(ctx, target)
+	if err != nil {
+		return nil, fmt.Errorf("备份当前二进制失败: %w", err)
+	}
+	if err := os.Rename(temp.Name(), target); err != nil {
+		if undo != nil {
+			undo()
+		}
+		return nil, err
+	}
+	manifest, err = b.load(target)
+	if err != nil {
+		manifest = &backupManifest{}
+	}
+	manifest.Current = &BinaryInfo{Version: chosen.Version, SourceTag: chosen.SourceTag, InstalledAt: time.Now()}
+	// 切换后的备份与当前二进制相同，下次替换时会重新保留；首项为刚保留的旧二进制。
+	kept := manifest.Backups[:0]
+	for i, backup := range manifest.Backups {
+		if i > 0 && backup.File == chosen.File {
+			os.Remove(filepath.Join(dir, backup.File))
+			continue
+		}
+		kept = append(kept, backup)
+	}
+	manifest.Backups = kept
+	b.prune(target, manifest)
+	if err := b.save(target, manifest); err != nil {
+		logs.ErrorfContext(ctx, "[%s] 写入备份记录失败: %v", filepath.Base(target), err)
+	}
+	return chosen, nil
+}
+
+// preserveLocked 返回的 undo 需在持有 b.mu 时调用，日志带上 ctx 中的操作标记。
+func (b *Backups) preserveLocked(ctx context.Context, target string) (func(), error) {
+	info, err := os.Stat(target)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := b.load(target)
+	if err != nil {
+		return nil, err
+	}
+	current := BinaryInfo{}
+	if manifest.Current != nil {
+		current = *manifest.Current
+	}
+	current.Version = b.version(target, current.Version)
+	if current.Version == "" {
+		current.Version = "unknown-" + info.ModTime().Format("20060102150405")
+	}
+	if current.InstalledAt.IsZero() {
+		current.InstalledAt = info.ModTime()
+	}
+	backup := Backup{
+		BinaryInfo: current,
+		File:       fmt.Sprintf("%s.%s.bak", filepath.Base(target), sanitizeVersion(current.Version)),
+		Size:       info.Size(),
+		ReplacedAt: time.Now(),
+	}
+	path := filepath.Join(filepath.Dir(target), backup.File)
+	staging := path + ".tmp"
+	os.Remove(staging)
+	// 优先硬链接，跨文件系统或不支持时退回复制。
+	if err := os.Link(target, staging); err != nil {
+		if err := copyBinary(target, staging); err != nil {
+			os.Remove(staging)
+			return nil, err
+		}
+	}
+	if err := os.Rename(staging, path); err != nil {
+		os.Remove(staging)
+		return nil, err
+	}
+
+	previous := manifest.Backups
+	kept := []Backup{backup}
+	for _, existing := range previous {
+		if existing.File != backup.File {
+			kept = append(kept, existing)
+		}
+	}
+	sort.SliceStable(kept, func(i, j int) bool { return kept[i].ReplacedAt.After(kept[j].ReplacedAt) })
+	manifest.Backups = kept
+	if err := b.save(target, manifest); err != nil {
+		return nil, err
+	}
+	logs.InfofContext(ctx, "[%s] 已保留旧内核 %s -> %s", filepath.Base(target), current.Version, backup.File)
+
+	undo := func() {
+		restored, err := b.load(target)
+		if err != nil {
+			return
+		}
+		restored.Backups = previous
+		if !containsBackup(previous, backup.File) {
+			os.Remove(path)
+		}
+		_ = b.save(target, restored)
+	}
+	return undo, nil
+}
+
+// prune 在替换完成后删除超出 Keep 的最旧备份；安装失败时 undo 需要完整的旧列表，因此不在 Preserve 中清理。
+func (b *Backups) prune(target string, manifest *backupManifest) {
+	keep := b.Keep
+	if keep <= 0 {
+		keep = 3
+	}
+	if len(manifest.Backups) <= keep {
+		return
+	}
+	for _, stale := range manifest.Backups[keep:] {
+		os.Remove(filepath.Join(filepath.Dir(target), stale.File))
+	}
+	manifest.Backups = manifest.Backups[:keep]
+}
+
+func (b *Backups) version(target, fallback string) string {
+	if b.Version == nil {
+		return fallback
+	}
+	version, err := b.Version(target)
+	if err != nil || strings.TrimSpace(version) == "" {
+		return fallback
+	}
+	return strings.TrimSpace(version)
+}
+
+func manifestPath(target string) string {
+	return filepath.Join(filepath.Dir(target), "."+filepath.Base(target)+".backups.json")
+}
+
+func (b *Backups) load(target string) (*backupManifest, error) {
+	manifest := &backupManifest{}
+	data, err := os.ReadFile(manifestPath(target))
+	if errors.Is(err, os.ErrNotExist) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %w", manifestPath(target), err)
+	}
+	return manifest, nil
+}
+
+func (b *Backups) save(target string, manifest *backupManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := manifestPath(target)
+	temp := path + ".tmp"
+	if err := os.WriteFile(temp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(temp, path)
+}
+
+func containsBackup(backups []Backup, file string) bool {
+	for _, backup := range backups {
+		if backup.File == file {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizeVersion 把版本号转换为可用作文件名的形式。
+func sanitizeVersion(version string) string {
+	var sb strings.Builder
+	for _, r := range version {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			sb.WriteRune(r)
+		default:
+			sb.WriteRune('_')
+		}
+	}
+	return sb.String()
+}
+
+func copyBinary(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
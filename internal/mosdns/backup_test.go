@@ -0,0 +1,170 @@
+package mosdns
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/herozmy/herobox/internal/logs"
+)
+
+// newTestBackups 以文件首行作为版本号。
+func newTestBackups(keep int) *Backups {
+	return &Backups{Keep: keep, Version: func(path string) (string, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		version, _, _ := strings.Cut(string(data), "\n")
+		return version, nil
+	}}
+}
+
+// installBinary 模拟一次更新：保留旧二进制，与 extract 一样以 rename 写入 content（备份可能是硬链接）并记录安装。
+func installBinary(t *testing.T, b *Backups, target, content string) {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := b.Preserve(ctx, target); err != nil {
+		t.Fatal(err)
+	}
+	temp := target + ".new"
+	if err := os.WriteFile(temp, []byte(content), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(temp, target); err != nil {
+		t.Fatal(err)
+	}
+	version, _, _ := strings.Cut(content, "\n")
+	b.Installed(ctx, target, version)
+}
+
+func backupVersions(t *testing.T, b *Backups, target string) []string {
+	t.Helper()
+	backups, _, err := b.List(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versions []string
+	for _, backup := range backups {
+		versions = append(versions, backup.Version)
+		if _, err := os.Stat(filepath.Join(filepath.Dir(target), backup.File)); err != nil {
+			t.Errorf("backup file of %s missing: %v", backup.Version, err)
+		}
+	}
+	return versions
+}
+
+func TestBackupsKeepNewest(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "mosdns")
+	b := newTestBackups(2)
+	for _, version := range []string{"v1", "v2", "v3", "v4"} {
+		installBinary(t, b, target, version)
+	}
+	if got := strings.Join(backupVersions(t, b, target), ","); got != "v3,v2" {
+		t.Fatalf("backups = %s, want v3,v2", got)
+	}
+	if _, err := os.Stat(target + ".v1.bak"); !os.IsNotExist(err) {
+		t.Fatalf("pruned backup still on disk: %v", err)
+	}
+	if _, current, _ := b.List(target); current == nil || current.Version != "v4" || current.SourceTag != "v4" {
+		t.Fatalf("current = %+v", current)
+	}
+}
+
+func TestBackupsUndo(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "mosdns")
+	b := newTestBackups(3)
+	installBinary(t, b, target, "v1")
+	installBinary(t, b, target, "v2")
+
+	undo, err := b.Preserve(context.Background(), target)
+	if err != nil || undo == nil {
+		t.Fatalf("Preserve: undo=%t, err=%v", undo != nil, err)
+	}
+	if got := strings.Join(backupVersions(t, b, target), ","); got != "v2,v1" {
+		t.Fatalf("backups after Preserve = %s", got)
+	}
+	undo()
+	if got := strings.Join(backupVersions(t, b, target), ","); got != "v1" {
+		t.Fatalf("backups after undo = %s, want v1", got)
+	}
+	if _, err := os.Stat(target + ".v2.bak"); !os.IsNotExist(err) {
+		t.Fatalf("undone backup still on disk: %v", err)
+	}
+
+	// target 不存在时无需保留。
+	if undo, err := b.Preserve(context.Background(), filepath.Join(t.TempDir(), "missing")); undo != nil || err != nil {
+		t.Fatalf("Preserve(missing): undo=%t, err=%v", undo != nil, err)
+	}
+}
+
+func TestBackupsRestore(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "mosdns")
+	b := newTestBackups(3)
+	for _, version := range []string{"v1", "v2", "v3"} {
+		installBinary(t, b, target, version)
+	}
+	restored, err := b.Restore(context.Background(), target, "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Version != "v1" {
+		t.Fatalf("restored = %+v", restored)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "v1" {
+		t.Fatalf("target = %q, want v1", data)
+	}
+	if got := strings.Join(backupVersions(t, b, target), ","); got != "v3,v2" {
+		t.Fatalf("backups after restore = %s, want v3,v2", got)
+	}
+	if _, current, _ := b.List(target); current == nil || current.Version != "v1" {
+		t.Fatalf("current = %+v", current)
+	}
+	if _, err := b.Restore(context.Background(), target, "v9"); err == nil {
+		t.Fatal("restoring an unknown version succeeded")
+	}
+}
+
+func TestBackupsRestoreSameVersion(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "mosdns")
+	b := newTestBackups(3)
+	installBinary(t, b, target, "v1")
+	installBinary(t, b, target, "v2\nbuild-a")
+	installBinary(t, b, target, "v2\nbuild-b")
+
+	if _, err := b.Restore(context.Background(), target, "v2"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "v2\nbuild-a" {
+		t.Fatalf("target = %q, want the backed up build", data)
+	}
+	// 同名备份被替换为切换前的二进制，两份内容都保留。
+	if data, _ := os.ReadFile(target + ".v2.bak"); string(data) != "v2\nbuild-b" {
+		t.Fatalf("backup = %q, want the replaced build", data)
+	}
+	if got := strings.Join(backupVersions(t, b, target), ","); got != "v2,v1" {
+		t.Fatalf("backups = %s, want v2,v1", got)
+	}
+}
+
+func TestBackupsLogWithOperation(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "mosdns")
+	b := newTestBackups(3)
+	installBinary(t, b, target, "v1")
+
+	var tagged []string
+	cancel := logs.Subscribe(func(entry logs.Entry) {
+		if entry.Operation == "update-1" {
+			tagged = append(tagged, entry.Message)
+		}
+	})
+	defer cancel()
+	if _, err := b.Preserve(logs.WithOperation(context.Background(), "update-1"), target); err != nil {
+		t.Fatal(err)
+	}
+	if len(tagged) != 1 || !strings.Contains(tagged[0], "已保留旧内核 v1") {
+		t.Fatalf("tagged logs = %q", tagged)
+	}
+}
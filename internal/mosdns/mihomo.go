@@ -0,0 +1,143 @@
+package mosdns
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const defaultMihomoRepo = "MetaCubeX/mihomo"
+
+// amd64 各微架构等级要求的 CPU flags（参考 GOAMD64 定义）。
+var amd64LevelFlags = map[int][]string{
+	2: {"cx16", "lahf_lm", "popcnt", "pni", "sse4_1", "sse4_2", "ssse3"},
+	3: {"avx", "avx2", "bmi1", "bmi2", "f16c", "fma", "abm", "movbe", "xsave"},
+}
+
+// NewMihomoUpdater 创建 mihomo 内核更新器，binaryPath 通常取自 MIHOMO_BIN。
+func NewMihomoUpdater(binaryPath string) *Updater {
+	repo := os.Getenv("MIHOMO_REPO")
+	if repo == "" {
+		repo = defaultMihomoRepo
+	}
+	if binaryPath == "" {
+		binaryPath = "/usr/local/bin/mihomo"
+	}
+	return &Updater{
+		Client:     NewClient(repo),
+		InstallDir: filepath.Dir(binaryPath),
+		Binary:     filepath.Base(binaryPath),
+		SelectAsset: func(assets []Asset) (Asset, error) {
+			level := 0
+			if runtime.GOARCH == "amd64" {
+				level = detectAMD64Level("/proc/cpuinfo")
+			}
+			return selectMihomoAsset(assets, runtime.GOARCH, level)
+		},
+	}
+}
+
+// selectMihomoAsset 在 mihomo-linux-<arch>[-<flavour>]-<version>.gz 中挑选最合适的资产。
+// amd64 下按 v<level>...v1、compatible、无后缀的顺序回退，其余架构只接受无后缀版本。
+func selectMihomoAsset(assets []Asset, goarch string, level int) (Asset, error) {
+	if len(assets) == 0 {
+		return Asset{}, errors.New("未找到可下载的资产")
+	}
+	arch := singBoxArch(goarch)
+	flavours := make(map[string]Asset)
+	for _, asset := range assets {
+		flavour, ok := mihomoFlavour(asset.Name, arch)
+		if !ok {
+			continue
+		}
+		if _, exists := flavours[flavour]; !exists {
+			flavours[flavour] = asset
+		}
+	}
+	var preferred []string
+	if goarch == "amd64" {
+		if level < 1 {
+			level = 1
+		}
+		for l := level; l >= 1; l-- {
+			preferred = append(preferred, fmt.Sprintf("v%d", l))
+		}
+		preferred = append(preferred, "compatible")
+	}
+	preferred = append(preferred, "")
+	for _, flavour := range preferred {
+		if asset, ok := flavours[flavour]; ok {
+			return asset, nil
+		}
+	}
+	return Asset{}, fmt.Errorf("未找到匹配 linux-%s 的 mihomo 资产", arch)
+}
+
+// mihomoFlavour 解析资产名中架构与版本号之间的变体标记，例如 amd64-v3-v1.19.0.gz 返回 v3。
+func mihomoFlavour(name, arch string) (string, bool) {
+	lower := strings.ToLower(name)
+	if !strings.HasSuffix(lower, ".gz") || strings.HasSuffix(lower, ".tar.gz") {
+		return "", false
+	}
+	prefix := "mihomo-linux-" + arch
+	if !strings.HasPrefix(lower, prefix+"-") && lower != prefix+".gz" {
+		return "", false
+	}
+	rest := strings.TrimSuffix(strings.TrimPrefix(lower, prefix), ".gz")
+	var tokens []string
+	for _, token := range strings.Split(strings.TrimPrefix(rest, "-"), "-") {
+		if isMihomoVersionToken(token) {
+			break
+		}
+		tokens = append(tokens, token)
+	}
+	flavour := strings.Join(tokens, "-")
+	// goXXX 为兼容旧 Go 运行时的构建，不作为候选。
+	if strings.Contains(flavour, "go1") {
+		return "", false
+	}
+	return flavour, true
+}
+
+func isMihomoVersionToken(token string) bool {
+	if token == "" || token == "alpha" || token == "beta" {
+		return true
+	}
+	return strings.HasPrefix(token, "v") && strings.Contains(token, ".")
+}
+
+// detectAMD64Level 读取 cpuinfo flags，返回支持的最高 GOAMD64 等级（1-3）。
+func detectAMD64Level(cpuinfo string) int {
+	f, err := os.Open(cpuinfo)
+	if err != nil {
+		return 1
+	}
+	defer f.Close()
+	flags := make(map[string]struct{})
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok || strings.TrimSpace(key) != "flags" {
+			continue
+		}
+		for _, flag := range strings.Fields(value) {
+			flags[flag] = struct{}{}
+		}
+		break
+	}
+	level := 1
+	for _, l := range []int{2, 3} {
+		for _, required := range amd64LevelFlags[l] {
+			if _, ok := flags[required]; !ok {
+				return level
+			}
+		}
+		level = l
+	}
+	return level
+}
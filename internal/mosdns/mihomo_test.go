@@ -0,0 +1,177 @@
+package mosdns
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// mihomoRelease 为 MetaCubeX/mihomo v1.19.10 发行版中 Linux 相关的资产名。
+var mihomoRelease = []string{
+	"mihomo-android-arm64-v8-v1.19.10.gz",
+	"mihomo-darwin-amd64-v1-v1.19.10.gz",
+	"mihomo-darwin-arm64-v1.19.10.gz",
+	"mihomo-linux-386-go120-v1.19.10.gz",
+	"mihomo-linux-386-v1.19.10.gz",
+	"mihomo-linux-amd64-compatible-v1.19.10.gz",
+	"mihomo-linux-amd64-v1-go120-v1.19.10.gz",
+	"mihomo-linux-amd64-v1-go122-v1.19.10.gz",
+	"mihomo-linux-amd64-v1-v1.19.10.gz",
+	"mihomo-linux-amd64-v1.19.10.gz",
+	"mihomo-linux-amd64-v2-go120-v1.19.10.gz",
+	"mihomo-linux-amd64-v2-v1.19.10.gz",
+	"mihomo-linux-amd64-v3-go120-v1.19.10.gz",
+	"mihomo-linux-amd64-v3-v1.19.10.deb",
+	"mihomo-linux-amd64-v3-v1.19.10.gz",
+	"mihomo-linux-amd64-v3-v1.19.10.rpm",
+	"mihomo-linux-arm64-v1.19.10.deb",
+	"mihomo-linux-arm64-v1.19.10.gz",
+	"mihomo-linux-armv5-v1.19.10.gz",
+	"mihomo-linux-armv6-v1.19.10.gz",
+	"mihomo-linux-armv7-v1.19.10.gz",
+	"mihomo-linux-mips-hardfloat-v1.19.10.gz",
+	"mihomo-windows-amd64-v3-v1.19.10.zip",
+}
+
+func assetsNamed(names ...string) []Asset {
+	assets := make([]Asset, 0, len(names))
+	for _, name := range names {
+		assets = append(assets, Asset{Name: name})
+	}
+	return assets
+}
+
+// without 返回去掉 drop 中资产名后的列表。
+func without(names []string, drop ...string) []string {
+	skip := make(map[string]bool, len(drop))
+	for _, name := range drop {
+		skip[name] = true
+	}
+	var kept []string
+	for _, name := range names {
+		if !skip[name] {
+			kept = append(kept, name)
+		}
+	}
+	return kept
+}
+
+func TestSelectMihomoAsset(t *testing.T) {
+	cases := []struct {
+		name   string
+		assets []string
+		goarch string
+		level  int
+		want   string
+	}{
+		{"amd64 v3", mihomoRelease, "amd64", 3, "mihomo-linux-amd64-v3-v1.19.10.gz"},
+		{"amd64 v2", mihomoRelease, "amd64", 2, "mihomo-linux-amd64-v2-v1.19.10.gz"},
+		{"amd64 v1", mihomoRelease, "amd64", 1, "mihomo-linux-amd64-v1-v1.19.10.gz"},
+		{"unknown level treated as v1", mihomoRelease, "amd64", 0, "mihomo-linux-amd64-v1-v1.19.10.gz"},
+		{"v3 missing falls back to v2", without(mihomoRelease, "mihomo-linux-amd64-v3-v1.19.10.gz"), "amd64", 3, "mihomo-linux-amd64-v2-v1.19.10.gz"},
+		{"only compatible and plain", []string{
+			"mihomo-linux-amd64-v1.19.10.gz",
+			"mihomo-linux-amd64-compatible-v1.19.10.gz",
+			"mihomo-linux-amd64-v3-go120-v1.19.10.gz",
+		}, "amd64", 3, "mihomo-linux-amd64-compatible-v1.19.10.gz"},
+		{"plain when only go builds remain", []string{
+			"mihomo-linux-amd64-v1-go120-v1.19.10.gz",
+			"mihomo-linux-amd64-v3-go122-v1.19.10.gz",
+			"mihomo-linux-amd64-v1.19.10.gz",
+		}, "amd64", 3, "mihomo-linux-amd64-v1.19.10.gz"},
+		{"alpha build", []string{"mihomo-linux-amd64-v2-alpha-e1a2b3c.gz", "mihomo-linux-amd64-alpha-e1a2b3c.gz"}, "amd64", 2, "mihomo-linux-amd64-v2-alpha-e1a2b3c.gz"},
+		{"arm64", mihomoRelease, "arm64", 0, "mihomo-linux-arm64-v1.19.10.gz"},
+		{"arm", mihomoRelease, "arm", 0, "mihomo-linux-armv7-v1.19.10.gz"},
+		{"386 skips go build", mihomoRelease, "386", 0, "mihomo-linux-386-v1.19.10.gz"},
+		{"no linux asset", []string{"mihomo-darwin-arm64-v1.19.10.gz", "mihomo-linux-amd64-v3-v1.19.10.deb"}, "amd64", 3, ""},
+		{"only go builds", []string{"mihomo-linux-amd64-v3-go120-v1.19.10.gz"}, "amd64", 3, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			asset, err := selectMihomoAsset(assetsNamed(tc.assets...), tc.goarch, tc.level)
+			if tc.want == "" {
+				if err == nil {
+					t.Fatalf("picked %s, want an error", asset.Name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if asset.Name != tc.want {
+				t.Fatalf("picked %s, want %s", asset.Name, tc.want)
+			}
+		})
+	}
+}
+
+func TestMihomoFlavour(t *testing.T) {
+	cases := []struct {
+		name    string
+		arch    string
+		flavour string
+		ok      bool
+	}{
+		{"mihomo-linux-amd64-v1.19.10.gz", "amd64", "", true},
+		{"mihomo-linux-amd64-v3-v1.19.10.gz", "amd64", "v3", true},
+		{"mihomo-linux-amd64-compatible-v1.19.10.gz", "amd64", "compatible", true},
+		{"mihomo-linux-amd64-v1-go120-v1.19.10.gz", "amd64", "", false},
+		{"mihomo-linux-amd64-v3-v1.19.10.deb", "amd64", "", false},
+		{"mihomo-linux-amd64-v3-v1.19.10.tar.gz", "amd64", "", false},
+		{"mihomo-linux-amd64.gz", "amd64", "", true},
+		{"mihomo-linux-arm64-v1.19.10.gz", "amd64", "", false},
+		{"mihomo-linux-armv7-v1.19.10.gz", "armv7", "", true},
+		{"mihomo-linux-armv7-v1.19.10.gz", "arm", "", false},
+		{"mihomo-linux-mips-hardfloat-v1.19.10.gz", "mips", "hardfloat", true},
+	}
+	for _, tc := range cases {
+		flavour, ok := mihomoFlavour(tc.name, tc.arch)
+		if flavour != tc.flavour || ok != tc.ok {
+			t.Errorf("mihomoFlavour(%s, %s) = %q, %v; want %q, %v", tc.name, tc.arch, flavour, ok, tc.flavour, tc.ok)
+		}
+	}
+}
+
+const (
+	// Intel Core 2 Duo：无 popcnt/sse4_2，只能运行 v1。
+	cpuinfoCore2 = "fpu vme de pse tsc msr pae mce cx8 apic sep mtrr pge mca cmov pat pse36 clflush dts acpi mmx fxsr sse sse2 ss ht tm pbe syscall nx lm constant_tsc arch_perfmon pebs bts rep_good nopl aperfmperf pni dtes64 monitor ds_cpl vmx est tm2 ssse3 cx16 xtpr pdcm lahf_lm"
+	// Intel Xeon X5650 (Westmere)：支持 v2，无 AVX。
+	cpuinfoWestmere = "fpu vme de pse tsc msr pae mce cx8 apic sep mtrr pge mca cmov pat pse36 clflush dts acpi mmx fxsr sse sse2 ss ht tm pbe syscall nx pdpe1gb rdtscp lm constant_tsc arch_perfmon pebs bts rep_good nopl xtopology nonstop_tsc cpuid aperfmperf pni pclmulqdq dtes64 monitor ds_cpl vmx smx est tm2 ssse3 cx16 xtpr pdcm pcid dca sse4_1 sse4_2 popcnt aes lahf_lm pti ssbd ibrs ibpb stibp tpr_shadow vnmi flexpriority ept vpid dtherm ida arat flush_l1d"
+	// Intel Core i7-4770 (Haswell)：支持 v3。
+	cpuinfoHaswell = "fpu vme de pse tsc msr pae mce cx8 apic sep mtrr pge mca cmov pat pse36 clflush dts acpi mmx fxsr sse sse2 ss ht tm pbe syscall nx pdpe1gb rdtscp lm constant_tsc arch_perfmon pebs bts rep_good nopl xtopology nonstop_tsc cpuid aperfmperf pni pclmulqdq dtes64 monitor ds_cpl vmx est tm2 ssse3 sdbg fma cx16 xtpr pdcm pcid sse4_1 sse4_2 x2apic movbe popcnt tsc_deadline_timer aes xsave avx f16c rdrand lahf_lm abm cpuid_fault epb invpcid_single pti ssbd ibrs ibpb stibp tpr_shadow vnmi flexpriority ept vpid ept_ad fsgsbase tsc_adjust bmi1 avx2 smep bmi2 erms invpcid xsaveopt dtherm ida arat pln pts md_clear flush_l1d"
+	// Intel Atom C2750 (Avoton)：有 movbe 但缺少 AVX，应停在 v2。
+	cpuinfoAvoton = "fpu vme de pse tsc msr pae mce cx8 apic sep mtrr pge mca cmov pat pse36 clflush dts acpi mmx fxsr sse sse2 ss ht tm pbe syscall nx rdtscp lm constant_tsc arch_perfmon pebs bts rep_good nopl xtopology nonstop_tsc cpuid aperfmperf pni pclmulqdq dtes64 monitor ds_cpl vmx est tm2 ssse3 cx16 xtpr pdcm sse4_1 sse4_2 movbe popcnt tsc_deadline_timer aes rdrand lahf_lm 3dnowprefetch cpuid_fault epb pti ibrs ibpb stibp tpr_shadow vnmi flexpriority ept vpid tsc_adjust smep erms dtherm ida arat md_clear"
+)
+
+func writeCPUInfo(t *testing.T, flags string) string {
+	t.Helper()
+	content := "processor\t: 0\nvendor_id\t: GenuineIntel\ncpu family\t: 6\nmodel name\t: test\nflags\t\t: " + flags + "\nbugs\t\t: spectre_v1\n\nprocessor\t: 1\nflags\t\t: fpu\n"
+	path := filepath.Join(t.TempDir(), "cpuinfo")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDetectAMD64Level(t *testing.T) {
+	cases := []struct {
+		name  string
+		flags string
+		want  int
+	}{
+		{"core2", cpuinfoCore2, 1},
+		{"westmere", cpuinfoWestmere, 2},
+		{"avoton", cpuinfoAvoton, 2},
+		{"haswell", cpuinfoHaswell, 3},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := detectAMD64Level(writeCPUInfo(t, tc.flags)); got != tc.want {
+				t.Fatalf("level = %d, want %d", got, tc.want)
+			}
+		})
+	}
+	if got := detectAMD64Level(filepath.Join(t.TempDir(), "missing")); got != 1 {
+		t.Fatalf("level without cpuinfo = %d, want 1", got)
+	}
+}
@@ -0,0 +1,70 @@
+package mosdns
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// 进度阶段。
+const (
+	PhaseResolve  = "resolve"  // 查询发行版、选择资产与校验和
+	PhaseDownload = "download" // 下载中，Bytes/Total 为已下载与总字节数
+	PhaseVerify   = "verify"   // 校验大小与 SHA-256
+	PhaseExtract  = "extract"  // 解压并写入
+	PhaseInstall  = "install"  // 替换完成后的收尾（记录版本、同步设置等）
+	PhaseRestart  = "restart"  // 重启服务
+)
+
+// Progress 是一条进度事件，Total 为 0 表示总大小未知。
+type Progress struct {
+	Phase   string `json:"phase"`
+	Message string `json:"message,omitempty"`
+	Bytes   int64  `json:"bytes,omitempty"`
+	Total   int64  `json:"total,omitempty"`
+}
+
+type progressKey struct{}
+
+// WithProgress 在 ctx 中注入进度回调，更新与下载流程通过 ReportProgress 上报。
+func WithProgress(ctx context.Context, fn func(Progress)) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// ReportProgress 向 ctx 中的回调上报进度，未注入回调时忽略。
+func ReportProgress(ctx context.Context, p Progress) {
+	if fn, ok := ctx.Value(progressKey{}).(func(Progress)); ok && fn != nil {
+		fn(p)
+	}
+}
+
+// ProgressReader 包装下载流，按 interval 节流上报 download 阶段的字节数，读完时补发最终进度。
+func ProgressReader(ctx context.Context, r io.Reader, total int64) io.Reader {
+	if _, ok := ctx.Value(progressKey{}).(func(Progress)); !ok {
+		return r
+	}
+	if total < 0 {
+		total = 0
+	}
+	return &progressReader{ctx: ctx, r: r, total: total}
+}
+
+const progressInterval = 250 * time.Millisecond
+
+type progressReader struct {
+	ctx   context.Context
+	r     io.Reader
+	total int64
+	read  int64
+	last  time.Time
+}
+
+func (p *progressReader) Read(buf []byte) (int, error) {
+	n, err := p.r.Read(buf)
+	p.read += int64(n)
+	if err == io.EOF || time.Since(p.last) >= progressInterval {
+		p.last = time.Now()
+		ReportProgress(p.ctx, Progress{Phase: PhaseDownload, Bytes: p.read, Total: p.total})
+	}
+	return n, err
+}
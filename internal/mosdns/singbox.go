@@ -0,0 +1,56 @@
+package mosdns
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const defaultSingBoxRepo = "SagerNet/sing-box"
+
+// NewSingBoxUpdater 创建 sing-box 内核更新器，安装路径默认 /usr/local/bin/sing-box。
+func NewSingBoxUpdater(binaryPath string) *Updater {
+	repo := os.Getenv("SING_BOX_REPO")
+	if repo == "" {
+		repo = defaultSingBoxRepo
+	}
+	if binaryPath == "" {
+		binaryPath = "/usr/local/bin/sing-box"
+	}
+	return &Updater{
+		Client:       NewClient(repo),
+		InstallDir:   filepath.Dir(binaryPath),
+		Binary:       filepath.Base(binaryPath),
+		ArchiveEntry: "sing-box",
+		SelectAsset: func(assets []Asset) (Asset, error) {
+			return selectSingBoxAsset(assets, runtime.GOARCH)
+		},
+	}
+}
+
+// selectSingBoxAsset 精确匹配 sing-box-<version>-linux-<arch>.tar.gz，
+// 避免误选 glibc/musl/v3 等变体。
+func selectSingBoxAsset(assets []Asset, goarch string) (Asset, error) {
+	if len(assets) == 0 {
+		return Asset{}, errors.New("未找到可下载的资产")
+	}
+	suffix := fmt.Sprintf("-linux-%s.tar.gz", singBoxArch(goarch))
+	for _, asset := range assets {
+		if strings.HasSuffix(strings.ToLower(asset.Name), suffix) {
+			return asset, nil
+		}
+	}
+	return Asset{}, fmt.Errorf("未找到匹配 linux-%s 的 sing-box 资产", singBoxArch(goarch))
+}
+
+func singBoxArch(goarch string) string {
+	switch goarch {
+	case "arm":
+		return "armv7"
+	default:
+		return goarch
+	}
+}
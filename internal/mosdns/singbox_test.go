@@ -0,0 +1,67 @@
+package mosdns
+
+import "testing"
+
+// singBoxRelease 为 SagerNet/sing-box v1.11.4 发行版资产名（节选）。
+var singBoxRelease = []string{
+	"sing-box-1.11.4-android-arm64.tar.gz",
+	"sing-box-1.11.4-darwin-arm64.tar.gz",
+	"sing-box-1.11.4-linux-386.tar.gz",
+	"sing-box-1.11.4-linux-amd64-glibc.tar.gz",
+	"sing-box-1.11.4-linux-amd64-legacy-go121.tar.gz",
+	"sing-box-1.11.4-linux-amd64-musl.tar.gz",
+	"sing-box-1.11.4-linux-amd64.tar.gz",
+	"sing-box-1.11.4-linux-amd64v3.tar.gz",
+	"sing-box-1.11.4-linux-arm64-glibc.tar.gz",
+	"sing-box-1.11.4-linux-arm64-musl.tar.gz",
+	"sing-box-1.11.4-linux-arm64.tar.gz",
+	"sing-box-1.11.4-linux-armv6.tar.gz",
+	"sing-box-1.11.4-linux-armv7-glibc.tar.gz",
+	"sing-box-1.11.4-linux-armv7-musl.tar.gz",
+	"sing-box-1.11.4-linux-armv7.tar.gz",
+	"sing-box-1.11.4-windows-amd64-legacy-windows-7.zip",
+	"sing-box-1.11.4-windows-amd64.zip",
+	"sing-box_1.11.4_linux_amd64.deb",
+	"sing-box_1.11.4_linux_amd64.pkg.tar.zst",
+	"sing-box_1.11.4_linux_amd64.rpm",
+}
+
+func TestSelectSingBoxAsset(t *testing.T) {
+	cases := []struct {
+		name   string
+		assets []string
+		goarch string
+		want   string
+	}{
+		{"amd64", singBoxRelease, "amd64", "sing-box-1.11.4-linux-amd64.tar.gz"},
+		{"arm64", singBoxRelease, "arm64", "sing-box-1.11.4-linux-arm64.tar.gz"},
+		{"arm", singBoxRelease, "arm", "sing-box-1.11.4-linux-armv7.tar.gz"},
+		{"386", singBoxRelease, "386", "sing-box-1.11.4-linux-386.tar.gz"},
+		{"only variants", []string{
+			"sing-box-1.11.4-linux-amd64-glibc.tar.gz",
+			"sing-box-1.11.4-linux-amd64-musl.tar.gz",
+			"sing-box-1.11.4-linux-amd64v3.tar.gz",
+			"sing-box-1.11.4-linux-amd64-legacy-go121.tar.gz",
+			"sing-box_1.11.4_linux_amd64.deb",
+		}, "amd64", ""},
+		{"missing arch", singBoxRelease, "riscv64", ""},
+		{"empty release", nil, "amd64", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			asset, err := selectSingBoxAsset(assetsNamed(tc.assets...), tc.goarch)
+			if tc.want == "" {
+				if err == nil {
+					t.Fatalf("picked %s, want an error", asset.Name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if asset.Name != tc.want {
+				t.Fatalf("picked %s, want %s", asset.Name, tc.want)
+			}
+		})
+	}
+}
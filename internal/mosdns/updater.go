@@ -5,18 +5,23 @@ import (
 	"archive/zip"
 	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"time"
 
 	"github.com/herozmy/herobox/internal/logs"
+	"github.com/herozmy/herobox/internal/outbound"
 )
 
 const defaultRepo = "yyysuo/mosdns"
@@ -24,6 +29,8 @@ const defaultRepo = "yyysuo/mosdns"
 // Release 描述 GitHub 发布。
 type Release struct {
 	TagName     string    `json:"tag_name"`
+	Name        string    `json:"name,omitempty"`
+	Prerelease  bool      `json:"prerelease,omitempty"`
 	PublishedAt time.Time `json:"published_at"`
 	Assets      []Asset   `json:"assets"`
 }
@@ -34,13 +41,15 @@ type Asset struct {
 	BrowserDownloadURL string `json:"browser_download_url"`
 	Size               int64  `json:"size"`
 	ContentType        string `json:"content_type"`
+	// Digest 为 GitHub 计算的摘要，例如 "sha256:<hex>"，旧发行版可能为空。
+	Digest string `json:"digest,omitempty"`
 }
 
 // Client 封装 GitHub 请求。
 type Client struct {
 	owner string
 	repo  string
-	http  *http.Client
+	http  *outbound.Client
 	token string
 }
 
@@ -58,17 +67,55 @@ func NewClient(repo string) *Client {
 	return &Client{
 		owner: owner,
 		repo:  name,
-		http: &http.Client{
-			Timeout: 15 * time.Second,
-		},
+		http:  outbound.Default,
 		token: os.Getenv("GITHUB_TOKEN"),
 	}
 }
 
 // LatestRelease 查询最新发行版。
 func (c *Client) LatestRelease(ctx context.Context) (*Release, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases/latest", c.owner, c.repo)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	var release Release
+	if _, err := c.getJSON(ctx, c.apiURL("releases/latest"), &release); err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+// ReleaseByTag 查询指定 tag 的发行版。
+func (c *Client) ReleaseByTag(ctx context.Context, tag string) (*Release, error) {
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return nil, errors.New("tag 不能为空")
+	}
+	var release Release
+	if _, err := c.getJSON(ctx, c.apiURL("releases/tags/"+url.PathEscape(tag)), &release); err != nil {
+		return nil, fmt.Errorf("获取发行版 %s 失败: %w", tag, err)
+	}
+	return &release, nil
+}
+
+// ListReleases 按发布时间倒序分页列出发行版，page 从 1 开始；hasMore 表示还有下一页。
+func (c *Client) ListReleases(ctx context.Context, page, perPage int) (releases []Release, hasMore bool, err error) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > 100 {
+		perPage = 10
+	}
+	header, err := c.getJSON(ctx, c.apiURL(fmt.Sprintf("releases?page=%d&per_page=%d", page, perPage)), &releases)
+	if err != nil {
+		return nil, false, err
+	}
+	return releases, strings.Contains(header.Get("Link"), `rel="next"`), nil
+}
+
+func (c *Client) apiURL(suffix string) string {
+	return fmt.Sprintf("https://api.github.com/repos/%s/%s/%s", c.owner, c.repo, suffix)
+}
+
+// getJSON 请求 GitHub API 并解码 JSON，返回响应头供分页使用。
+func (c *Client) getJSON(ctx context.Context, endpoint string, v any) (http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -85,19 +132,29 @@ func (c *Client) LatestRelease(ctx context.Context) (*Release, error) {
 	if resp.StatusCode >= 400 {
 		return nil, fmt.Errorf("github api %s", resp.Status)
 	}
-	logs.Infof("[mosdns] GitHub %s/%s 状态 %s", c.owner, c.repo, resp.Status)
-	var release Release
-	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+	logs.InfofContext(ctx, "[mosdns] GitHub %s/%s 状态 %s", c.owner, c.repo, resp.Status)
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
 		return nil, err
 	}
-	return &release, nil
+	return resp.Header, nil
 }
 
-// Updater 负责下载/解压 mosdns 内核。
+// Updater 负责下载/解压内核二进制，默认面向 mosdns。
 type Updater struct {
 	Client     *Client
 	InstallDir string
 	AssetHint  string
+	// Binary 为安装后的文件名，为空时使用 mosdns。
+	Binary string
+	// ArchiveEntry 为归档内可执行文件的名称，为空时与 Binary 相同。
+	ArchiveEntry string
+	// SelectAsset 可覆盖默认的资产匹配逻辑。
+	SelectAsset func(assets []Asset) (Asset, error)
+	// Backups 可选：替换前保留旧二进制，支持回退。
+	Backups *Backups
+	// TargetPath 可选：每次安装时返回安装路径，非空时优先于 InstallDir/Binary，
+	// 用于跟随运行时修改的服务二进制路径。
+	TargetPath func() string
 }
 
 // DefaultUpdater 简化创建。
@@ -119,39 +176,113 @@ func DefaultUpdater() *Updater {
 
 // UpdateLatest 下载最新发行版，并尝试将核心二进制写入 InstallDir。
 func (u *Updater) UpdateLatest(ctx context.Context) (*Release, string, error) {
+	return u.install(ctx, "最新发行版", func(c *Client) (*Release, error) {
+		return c.LatestRelease(ctx)
+	})
+}
+
+// InstallTag 下载指定 tag 的发行版并写入 InstallDir，用于回退到已知可用的版本。
+func (u *Updater) InstallTag(ctx context.Context, tag string) (*Release, string, error) {
+	return u.install(ctx, "发行版 "+tag, func(c *Client) (*Release, error) {
+		return c.ReleaseByTag(ctx, tag)
+	})
+}
+
+// PickAsset 返回当前平台会选用的资产。
+func (u *Updater) PickAsset(assets []Asset) (Asset, error) {
+	if u.SelectAsset != nil {
+		return u.SelectAsset(assets)
+	}
+	return selectAsset(assets, u.AssetHint)
+}
+
+func (u *Updater) install(ctx context.Context, label string, fetch func(*Client) (*Release, error)) (*Release, string, error) {
 	if u.Client == nil {
 		u.Client = NewClient(defaultRepo)
 	}
-	if u.InstallDir == "" {
-		u.InstallDir = "/usr/local/bin"
+	target := u.Target()
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return nil, "", err
 	}
-	if err := os.MkdirAll(u.InstallDir, 0o755); err != nil {
+
+	binary := filepath.Base(target)
+	prefix := "[" + binary + "]"
+	logs.InfofContext(ctx, "%s 正在检测仓库 %s/%s %s", prefix, u.Client.owner, u.Client.repo, label)
+	ReportProgress(ctx, Progress{Phase: PhaseResolve, Message: label})
+	rel, err := fetch(u.Client)
+	if err != nil {
+		logs.ErrorfContext(ctx, "%s 获取%s失败: %v", prefix, label, err)
 		return nil, "", err
 	}
 
-	logs.Infof("[mosdns] 正在检测仓库 %s/%s 最新发行版", u.Client.owner, u.Client.repo)
-	rel, err := u.Client.LatestRelease(ctx)
+	asset, err := u.PickAsset(rel.Assets)
 	if err != nil {
-		logs.Errorf("[mosdns] 获取最新发行版失败: %v", err)
+		logs.ErrorfContext(ctx, "%s 选择配置失败: %v", prefix, err)
 		return nil, "", err
 	}
 
-	asset, err := selectAsset(rel.Assets, u.AssetHint)
+	expect, err := u.Client.expectation(ctx, rel, asset)
 	if err != nil {
-		logs.Errorf("[mosdns] 选择配置失败: %v", err)
+		logs.ErrorfContext(ctx, "%s %v，已取消更新", prefix, err)
 		return nil, "", err
 	}
+	if expect.SHA256 != "" {
+		logs.InfofContext(ctx, "%s %s 期望 SHA-256 %s（来自 %s）", prefix, asset.Name, expect.SHA256, expect.Source)
+	} else {
+		logs.InfofContext(ctx, "%s 发行版未提供 %s 的校验和，仅校验大小", prefix, asset.Name)
+	}
 
-	target := filepath.Join(u.InstallDir, "mosdns")
-	logs.Infof("[mosdns] 下载配置 %s -> %s", asset.Name, target)
-	if err := downloadAndExtract(ctx, asset.BrowserDownloadURL, target); err != nil {
-		logs.Errorf("[mosdns] 下载或解压失败: %v", err)
+	var undo func()
+	if u.Backups != nil {
+		if undo, err = u.Backups.Preserve(ctx, target); err != nil {
+			logs.ErrorfContext(ctx, "%s 保留旧内核失败，已取消更新: %v", prefix, err)
+			return nil, "", fmt.Errorf("保留旧内核失败: %w", err)
+		}
+	}
+	logs.InfofContext(ctx, "%s 下载配置 %s -> %s", prefix, asset.Name, target)
+	if err := downloadAndExtract(ctx, u.Client.http, asset.BrowserDownloadURL, target, u.entryName(), expect); err != nil {
+		logs.ErrorfContext(ctx, "%s 下载或解压失败: %v", prefix, err)
+		if undo != nil {
+			undo()
+		}
 		return nil, "", err
 	}
-	logs.Infof("[mosdns] mosdns 内核更新完成 -> %s", target)
+	ReportProgress(ctx, Progress{Phase: PhaseInstall, Message: rel.TagName})
+	if u.Backups != nil {
+		u.Backups.Installed(ctx, target, rel.TagName)
+	}
+	logs.InfofContext(ctx, "%s %s 内核 %s 安装完成 -> %s", prefix, binary, rel.TagName, target)
 	return rel, target, nil
 }
 
+// Target 返回内核安装路径。
+func (u *Updater) Target() string {
+	if u.TargetPath != nil {
+		if target := strings.TrimSpace(u.TargetPath()); target != "" {
+			return target
+		}
+	}
+	dir := u.InstallDir
+	if dir == "" {
+		dir = "/usr/local/bin"
+	}
+	return filepath.Join(dir, u.binaryName())
+}
+
+func (u *Updater) binaryName() string {
+	if u.Binary != "" {
+		return u.Binary
+	}
+	return "mosdns"
+}
+
+func (u *Updater) entryName() string {
+	if u.ArchiveEntry != "" {
+		return u.ArchiveEntry
+	}
+	return u.binaryName()
+}
+
 func selectAsset(assets []Asset, hint string) (Asset, error) {
 	if len(assets) == 0 {
 		return Asset{}, errors.New("未找到可下载的资产")
@@ -184,12 +315,13 @@ func matchAsset(name string, filters []string) bool {
 	return true
 }
 
-func downloadAndExtract(ctx context.Context, url, target string) error {
+// downloadAndExtract 下载资产并按 expect 校验大小与 SHA-256，校验通过后才解压写入 target。
+func downloadAndExtract(ctx context.Context, client *outbound.Client, url, target, entry string, expect assetExpectation) error {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return err
 	}
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := client.Download(req)
 	if err != nil {
 		return err
 	}
@@ -198,62 +330,89 @@ func downloadAndExtract(ctx context.Context, url, target string) error {
 		return fmt.Errorf("下载失败：%s", resp.Status)
 	}
 
-	tempFile, err := os.CreateTemp("", "mosdns-asset-*.bin")
+	tempFile, err := os.CreateTemp("", entry+"-asset-*.bin")
 	if err != nil {
 		return err
 	}
 	defer os.Remove(tempFile.Name())
 
-	if _, err := io.Copy(tempFile, resp.Body); err != nil {
-		tempFile.Close()
-		return err
+	hash := sha256.New()
+	total := expect.Size
+	if total <= 0 {
+		total = resp.ContentLength
 	}
+	written, err := io.Copy(io.MultiWriter(tempFile, hash), ProgressReader(ctx, resp.Body, total))
 	tempFile.Close()
+	if err != nil {
+		return err
+	}
+	ReportProgress(ctx, Progress{Phase: PhaseVerify})
+	if expect.Size > 0 && written != expect.Size {
+		return fmt.Errorf("下载内容大小不匹配：期望 %d 字节，实际 %d 字节", expect.Size, written)
+	}
+	if expect.SHA256 != "" {
+		if actual := hex.EncodeToString(hash.Sum(nil)); actual != expect.SHA256 {
+			return fmt.Errorf("SHA-256 校验失败：期望 %s（来自 %s），实际 %s", expect.SHA256, expect.Source, actual)
+		}
+	}
 
 	// 根据扩展名决定如何处理
+	ReportProgress(ctx, Progress{Phase: PhaseExtract})
 	switch {
 	case strings.HasSuffix(url, ".zip"):
-		return extractZip(tempFile.Name(), target)
+		return extractZip(tempFile.Name(), target, entry)
 	case strings.HasSuffix(url, ".tar.gz") || strings.HasSuffix(url, ".tgz"):
-		return extractTarGz(tempFile.Name(), target)
+		return extractTarGz(tempFile.Name(), target, entry)
+	case strings.HasSuffix(url, ".gz"):
+		return extractGzip(tempFile.Name(), target)
 	default:
 		return moveBinary(tempFile.Name(), target)
 	}
 }
 
-func extractZip(src, target string) error {
+func extractZip(src, target, entry string) error {
 	r, err := zip.OpenReader(src)
 	if err != nil {
 		return err
 	}
 	defer r.Close()
-	for _, f := range r.File {
-		if f.FileInfo().IsDir() {
-			continue
+	for _, match := range entryMatchers(entry) {
+		for _, f := range r.File {
+			if f.FileInfo().IsDir() || !match(f.Name) {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			defer rc.Close()
+			return writeBinary(rc, target, f.Mode())
 		}
-		if !strings.Contains(strings.ToLower(f.Name), "mosdns") {
-			continue
-		}
-		rc, err := f.Open()
-		if err != nil {
+	}
+	return fmt.Errorf("zip 未找到 %s 可执行文件", entry)
+}
+
+func extractTarGz(src, target, entry string) error {
+	for _, match := range entryMatchers(entry) {
+		found, err := extractTarGzEntry(src, target, match)
+		if err != nil || found {
 			return err
 		}
-		defer rc.Close()
-		return writeBinary(rc, target, f.Mode())
 	}
-	return errors.New("zip 未找到 mosdns 可执行文件")
+	return fmt.Errorf("tar.gz 未找到 %s 可执行文件", entry)
 }
 
-func extractTarGz(src, target string) error {
+// extractTarGzEntry 写入第一个匹配的条目，tar 只能顺序读取，每种匹配方式各遍历一次。
+func extractTarGzEntry(src, target string, match func(string) bool) (bool, error) {
 	file, err := os.Open(src)
 	if err != nil {
-		return err
+		return false, err
 	}
 	defer file.Close()
 
 	gz, err := gzip.NewReader(file)
 	if err != nil {
-		return err
+		return false, err
 	}
 	defer gz.Close()
 
@@ -261,20 +420,46 @@ func extractTarGz(src, target string) error {
 	for {
 		hdr, err := tr.Next()
 		if errors.Is(err, io.EOF) {
-			break
+			return false, nil
 		}
 		if err != nil {
-			return err
-		}
-		if hdr.FileInfo().IsDir() {
-			continue
+			return false, err
 		}
-		if !strings.Contains(strings.ToLower(hdr.Name), "mosdns") {
+		if hdr.FileInfo().IsDir() || !match(hdr.Name) {
 			continue
 		}
-		return writeBinary(tr, target, hdr.FileInfo().Mode())
+		return true, writeBinary(tr, target, hdr.FileInfo().Mode())
 	}
-	return errors.New("tar.gz 未找到 mosdns 可执行文件")
+}
+
+// extractGzip 处理单文件 .gz 资产（例如 mihomo），解压后的内容即为可执行文件。
+func extractGzip(src, target string) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	return writeBinary(gz, target, 0o755)
+}
+
+// entryMatchers 返回按优先级排列的条目匹配方式：先精确匹配文件名，
+// 找不到时退回文件名包含 entry（例如 mosdns-linux-amd64），两者均忽略所在的嵌套目录。
+func entryMatchers(entry string) []func(string) bool {
+	entry = strings.ToLower(entry)
+	return []func(string) bool{
+		func(name string) bool { return entryBase(name) == entry },
+		func(name string) bool { return strings.Contains(entryBase(name), entry) },
+	}
+}
+
+func entryBase(name string) string {
+	return strings.ToLower(path.Base(strings.ReplaceAll(name, "\\", "/")))
 }
 
 func moveBinary(src, target string) error {
@@ -287,17 +472,20 @@ func moveBinary(src, target string) error {
 }
 
 func writeBinary(r io.Reader, target string, mode os.FileMode) error {
-	temp, err := os.CreateTemp(filepath.Dir(target), "mosdns-*")
+	temp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+"-*")
 	if err != nil {
 		return err
 	}
 	defer os.Remove(temp.Name())
 
-	if _, err := io.Copy(temp, r); err != nil {
-		temp.Close()
+	written, err := io.Copy(temp, r)
+	temp.Close()
+	if err != nil {
 		return err
 	}
-	temp.Close()
+	if written == 0 {
+		return fmt.Errorf("解压得到的 %s 为空文件", filepath.Base(target))
+	}
 
 	if mode == 0 {
 		mode = 0o755
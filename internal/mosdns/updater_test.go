@@ -0,0 +1,111 @@
+package mosdns
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTarGz(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o755, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(content))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractArchiveEntry(t *testing.T) {
+	cases := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{"exact", map[string]string{"dist/mosdns": "exact", "dist/mosdns-linux-amd64": "fallback"}, "exact"},
+		{"substring fallback", map[string]string{"README.md": "readme", "mosdns-linux-amd64": "fallback"}, "fallback"},
+		{"missing", map[string]string{"README.md": "readme"}, ""},
+	}
+	for _, tc := range cases {
+		for _, format := range []string{"zip", "tar.gz"} {
+			t.Run(tc.name+"/"+format, func(t *testing.T) {
+				dir := t.TempDir()
+				archive := filepath.Join(dir, "asset."+format)
+				target := filepath.Join(dir, "mosdns")
+				var err error
+				if format == "zip" {
+					writeZip(t, archive, tc.files)
+					err = extractZip(archive, target, "mosdns")
+				} else {
+					writeTarGz(t, archive, tc.files)
+					err = extractTarGz(archive, target, "mosdns")
+				}
+				if tc.want == "" {
+					if err == nil {
+						t.Fatal("expected error for archive without mosdns entry")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				data, err := os.ReadFile(target)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(data) != tc.want {
+					t.Fatalf("extracted %q, want %q", data, tc.want)
+				}
+			})
+		}
+	}
+}
+
+func TestUpdaterTargetFollowsTargetPath(t *testing.T) {
+	current := "/opt/sing-box/bin/sing-box"
+	u := NewSingBoxUpdater("/usr/local/bin/sing-box")
+	u.TargetPath = func() string { return current }
+	if got := u.Target(); got != current {
+		t.Fatalf("Target() = %s, want %s", got, current)
+	}
+	current = ""
+	if got := u.Target(); got != "/usr/local/bin/sing-box" {
+		t.Fatalf("Target() with empty TargetPath = %s, want the default install path", got)
+	}
+}
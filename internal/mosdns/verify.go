@@ -0,0 +1,115 @@
+package mosdns
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// assetExpectation 为下载资产的校验依据，零值字段表示不校验该项。
+type assetExpectation struct {
+	Size   int64
+	SHA256 string
+	// Source 说明 SHA256 的来源，用于日志。
+	Source string
+}
+
+// checksumAssetNames 为发行版中汇总校验和文件的常见名称（小写比较）。
+var checksumAssetNames = []string{"checksums.txt", "sha256sums", "sha256sums.txt", "sha256sum.txt", "checksums.sha256"}
+
+// expectation 确定资产的期望大小与 SHA-256：优先使用 GitHub 提供的 digest，
+// 其次是同名 .sha256/.sha256sum 文件，最后是 checksums.txt 等汇总文件。
+// 找到校验文件但下载或解析失败时返回错误，避免在校验缺失的情况下静默安装。
+func (c *Client) expectation(ctx context.Context, rel *Release, asset Asset) (assetExpectation, error) {
+	expect := assetExpectation{Size: asset.Size}
+	if algo, sum, ok := strings.Cut(asset.Digest, ":"); ok && strings.EqualFold(algo, "sha256") && isSHA256(sum) {
+		expect.SHA256 = strings.ToLower(sum)
+		expect.Source = "GitHub digest"
+		return expect, nil
+	}
+	lowerName := strings.ToLower(asset.Name)
+	for _, candidate := range rel.Assets {
+		name := strings.ToLower(candidate.Name)
+		if name != lowerName+".sha256" && name != lowerName+".sha256sum" {
+			continue
+		}
+		sum, err := c.fetchChecksum(ctx, candidate, asset.Name, true)
+		if err != nil {
+			return expect, fmt.Errorf("读取校验文件 %s 失败: %w", candidate.Name, err)
+		}
+		expect.SHA256, expect.Source = sum, candidate.Name
+		return expect, nil
+	}
+	for _, candidate := range rel.Assets {
+		name := strings.ToLower(candidate.Name)
+		matched := false
+		for _, known := range checksumAssetNames {
+			if name == known || strings.HasSuffix(name, "_"+known) || strings.HasSuffix(name, "-"+known) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		sum, err := c.fetchChecksum(ctx, candidate, asset.Name, false)
+		if err != nil {
+			return expect, fmt.Errorf("读取校验文件 %s 失败: %w", candidate.Name, err)
+		}
+		expect.SHA256, expect.Source = sum, candidate.Name
+		return expect, nil
+	}
+	return expect, nil
+}
+
+// fetchChecksum 下载校验文件并查找 name 对应的 SHA-256；single 为 true 时文件只描述一个资产，
+// 允许仅包含哈希值。
+func (c *Client) fetchChecksum(ctx context.Context, checksum Asset, name string, single bool) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, checksum.BrowserDownloadURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("下载失败：%s", resp.Status)
+	}
+	scanner := bufio.NewScanner(io.LimitReader(resp.Body, 1<<20))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || !isSHA256(fields[0]) {
+			continue
+		}
+		if len(fields) == 1 {
+			if single {
+				return strings.ToLower(fields[0]), nil
+			}
+			continue
+		}
+		// sha256sum 输出格式为 "<hash>  <file>"，二进制模式在文件名前带 *。
+		file := path.Base(strings.TrimPrefix(fields[len(fields)-1], "*"))
+		if file == name || (single && strings.EqualFold(file, name)) {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("未找到 %s 的 SHA-256", name)
+}
+
+func isSHA256(value string) bool {
+	if len(value) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(value)
+	return err == nil
+}
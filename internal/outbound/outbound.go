@@ -0,0 +1,250 @@
+// Package outbound 为 HeroBox 访问 GitHub 等外部资源的 HTTP 请求提供统一出口：
+// 代理（HTTP/HTTPS/SOCKS5）、URL 改写（镜像加速）与按请求的超时。
+package outbound
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/herozmy/herobox/internal/logs"
+)
+
+// 代理取值：ProxyEnv 使用 HTTP_PROXY/HTTPS_PROXY/NO_PROXY 环境变量，ProxyDirect 强制直连，
+// 其余为 http://、https://、socks5:// 或 socks5h:// 代理地址。
+const (
+	ProxyEnv    = ""
+	ProxyDirect = "direct"
+)
+
+// DefaultTimeout 为未配置时 API 等小请求的超时。
+const DefaultTimeout = 15 * time.Second
+
+// Rewrite 把以 Prefix 开头的 URL 替换为 Replacement + 剩余部分。
+type Rewrite struct {
+	Prefix      string
+	Replacement string
+}
+
+// Options 描述出站请求的代理、改写规则与超时。
+type Options struct {
+	Proxy    string
+	Rewrites []Rewrite
+	// Timeout 限制 Do 发出的单次请求（含读取响应体），<= 0 时使用 DefaultTimeout。
+	Timeout time.Duration
+	// DownloadTimeout 限制 Download 发出的单次请求，<= 0 时仅受调用方 ctx 约束。
+	DownloadTimeout time.Duration
+}
+
+// Client 按当前 Options 发送请求，设置修改后通过 SetOptions 替换即可生效；
+// 按代理地址缓存 Transport 以复用连接，代理变更时关闭旧 Transport。
+type Client struct {
+	mu         sync.Mutex
+	options    Options
+	transports map[string]*http.Transport
+}
+
+// Default 为全局出站客户端，由 main 在加载与修改设置时通过 SetOptions 更新。
+var Default = New(Options{})
+
+// New 创建出站客户端，零值 Options 表示使用环境变量代理与默认超时。
+func New(opts Options) *Client {
+	return &Client{options: opts, transports: make(map[string]*http.Transport)}
+}
+
+// SetOptions 替换选项，并关闭不再使用的代理对应的 Transport。
+func (c *Client) SetOptions(opts Options) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.options = opts
+	current := strings.TrimSpace(opts.Proxy)
+	for proxy, t := range c.transports {
+		if proxy != current {
+			t.CloseIdleConnections()
+			delete(c.transports, proxy)
+		}
+	}
+}
+
+// Options 返回当前生效的选项。
+func (c *Client) Options() Options {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.options
+}
+
+// Do 发送 API、校验文件等小请求，受 Options.Timeout 限制。
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	opts := c.Options()
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return c.do(req, opts, timeout)
+}
+
+// Download 发送大文件下载请求，受 Options.DownloadTimeout 限制。
+func (c *Client) Download(req *http.Request) (*http.Response, error) {
+	opts := c.Options()
+	return c.do(req, opts, opts.DownloadTimeout)
+}
+
+func (c *Client) do(req *http.Request, opts Options, timeout time.Duration) (*http.Response, error) {
+	transport, err := c.transport(opts.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	original := req.URL.String()
+	if target, ok := Apply(opts.Rewrites, original); ok {
+		rewritten, err := url.Parse(target)
+		if err != nil {
+			return nil, fmt.Errorf("改写后的地址 %s 无效: %w", target, err)
+		}
+		req = req.Clone(req.Context())
+		req.URL = rewritten
+		req.Host = ""
+		// 改写到其他主机时不携带 GITHUB_TOKEN 等凭据，避免泄露给镜像。
+		if rewritten.Host != parsedHost(original) {
+			req.Header.Del("Authorization")
+		}
+		logs.InfofContext(req.Context(), "[outbound] %s -> %s", original, target)
+	}
+
+	cancel := context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, cancelTimeout := context.WithTimeout(req.Context(), timeout)
+		req = req.WithContext(ctx)
+		cancel = cancelTimeout
+	}
+	resp, err := (&http.Client{Transport: transport}).Do(req)
+	if err != nil {
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) && timeout > 0 {
+			return nil, fmt.Errorf("请求 %s 超时（%s）: %w", req.URL.Redacted(), timeout, err)
+		}
+		return nil, err
+	}
+	// 超时覆盖响应体读取，关闭响应体时释放计时器。
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// transport 返回 proxy 对应的 Transport，参数与 http.DefaultTransport 一致。
+func (c *Client) transport(proxy string) (*http.Transport, error) {
+	proxy = strings.TrimSpace(proxy)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t, ok := c.transports[proxy]; ok {
+		return t, nil
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	switch strings.ToLower(proxy) {
+	case ProxyEnv:
+		t.Proxy = http.ProxyFromEnvironment
+	case ProxyDirect:
+		t.Proxy = nil
+	default:
+		proxyURL, err := ParseProxy(proxy)
+		if err != nil {
+			return nil, err
+		}
+		t.Proxy = http.ProxyURL(proxyURL)
+	}
+	// 只缓存当前设置的代理，避免与 SetOptions 并发时留下已被替换的代理。
+	if proxy == strings.TrimSpace(c.options.Proxy) {
+		c.transports[proxy] = t
+	}
+	return t, nil
+}
+
+// ParseProxy 校验代理地址，支持 http、https、socks5、socks5h。
+func ParseProxy(proxy string) (*url.URL, error) {
+	proxyURL, err := url.Parse(strings.TrimSpace(proxy))
+	if err != nil {
+		return nil, fmt.Errorf("代理地址 %s 无效: %w", proxy, err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("代理地址 %s 无效：仅支持 http://、https://、socks5://、socks5h://", proxy)
+	}
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("代理地址 %s 缺少主机与端口", proxy)
+	}
+	return proxyURL, nil
+}
+
+// Apply 按顺序匹配改写规则，返回第一个匹配规则改写后的地址。
+func Apply(rules []Rewrite, target string) (string, bool) {
+	for _, rule := range rules {
+		if rule.Prefix != "" && strings.HasPrefix(target, rule.Prefix) {
+			return rule.Replacement + strings.TrimPrefix(target, rule.Prefix), true
+		}
+	}
+	return target, false
+}
+
+// MirrorRewrites 生成 ghproxy 式镜像规则：github.com 与 raw.githubusercontent.com 的地址改写为 mirror + 原地址。
+// api.github.com 通常不被此类镜像支持，需要时通过单独的规则配置。
+func MirrorRewrites(mirror string) []Rewrite {
+	mirror = strings.TrimSpace(mirror)
+	if mirror == "" {
+		return nil
+	}
+	if !strings.HasSuffix(mirror, "/") {
+		mirror += "/"
+	}
+	var rules []Rewrite
+	for _, prefix := range []string{"https://github.com/", "https://raw.githubusercontent.com/"} {
+		rules = append(rules, Rewrite{Prefix: prefix, Replacement: mirror + prefix})
+	}
+	return rules
+}
+
+// ParseRewrites 解析 "前缀=替换" 形式的规则，规则之间以换行、逗号或分号分隔，例如
+// "https://api.github.com/=https://gh-api.example.com/"。
+func ParseRewrites(value string) ([]Rewrite, error) {
+	var rules []Rewrite
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool { return r == '\n' || r == ',' || r == ';' }) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		prefix, replacement, ok := strings.Cut(item, "=")
+		prefix, replacement = strings.TrimSpace(prefix), strings.TrimSpace(replacement)
+		if !ok || prefix == "" || replacement == "" {
+			return nil, fmt.Errorf("改写规则 %q 无效，应为 前缀=替换", item)
+		}
+		for _, part := range []string{prefix, replacement} {
+			if u, err := url.Parse(part); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return nil, fmt.Errorf("改写规则 %q 无效：%s 不是 http(s) 地址", item, part)
+			}
+		}
+		rules = append(rules, Rewrite{Prefix: prefix, Replacement: replacement})
+	}
+	return rules, nil
+}
+
+func parsedHost(raw string) string {
+	if u, err := url.Parse(raw); err == nil {
+		return u.Host
+	}
+	return ""
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
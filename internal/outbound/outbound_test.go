@@ -0,0 +1,45 @@
+package outbound
+
+import "testing"
+
+func TestSetOptionsDropsStaleTransports(t *testing.T) {
+	c := New(Options{Proxy: "http://127.0.0.1:8080"})
+	first, err := c.transport(c.Options().Proxy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := c.transport(c.Options().Proxy); again != first {
+		t.Fatal("transport for the current proxy is not reused")
+	}
+
+	c.SetOptions(Options{Proxy: "socks5h://127.0.0.1:1080"})
+	if _, ok := c.transports["http://127.0.0.1:8080"]; ok {
+		t.Fatal("transport for the previous proxy was kept")
+	}
+	if _, err := c.transport(c.Options().Proxy); err != nil {
+		t.Fatal(err)
+	}
+	// 已被替换的代理不再进入缓存。
+	if _, err := c.transport("http://127.0.0.1:8080"); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.transports) != 1 {
+		t.Fatalf("cached transports = %d, want 1", len(c.transports))
+	}
+}
+
+func TestParseRewrites(t *testing.T) {
+	rules, err := ParseRewrites("https://api.github.com/=https://gh-api.example.com/\nhttps://github.com/=https://mirror.example.com/https://github.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := Apply(rules, "https://api.github.com/repos/a/b/releases/latest")
+	if !ok || got != "https://gh-api.example.com/repos/a/b/releases/latest" {
+		t.Fatalf("Apply = %q, %v", got, ok)
+	}
+	for _, bad := range []string{"https://github.com/", "ftp://a/=https://b/", "=https://b/"} {
+		if _, err := ParseRewrites(bad); err == nil {
+			t.Errorf("ParseRewrites(%q) accepted an invalid rule", bad)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package procstat
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Process 是按二进制路径匹配到的进程。
+type Process struct {
+	PID     int      `json:"pid"`
+	Exe     string   `json:"exe,omitempty"`
+	Cmdline []string `json:"cmdline,omitempty"`
+	// MatchesHint 表示命令行中包含调用方给出的配置路径。
+	MatchesHint bool `json:"matchesHint"`
+}
+
+// Find 扫描 procRoot 下的进程，返回可执行文件（/proc/<pid>/exe，读取失败时退回 argv[0]）
+// 与 binaries 之一相同的进程。hint 非空时命令行包含 hint 的进程排在前面，其余按 PID 升序。
+// 二进制被替换后内核会在 exe 后追加 " (deleted)"，匹配时会忽略该后缀；僵尸进程与当前进程不计入。
+func Find(procRoot string, binaries []string, hint string) []Process {
+	if procRoot == "" {
+		procRoot = "/proc"
+	}
+	targets := make(map[string]bool)
+	for _, bin := range binaries {
+		if bin == "" {
+			continue
+		}
+		targets[filepath.Clean(bin)] = true
+		if resolved, err := filepath.EvalSymlinks(bin); err == nil {
+			targets[resolved] = true
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return nil
+	}
+	self := os.Getpid()
+	var found []Process
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid == self {
+			continue
+		}
+		dir := filepath.Join(procRoot, entry.Name())
+		cmdline := readCmdline(filepath.Join(dir, "cmdline"))
+		if len(cmdline) == 0 {
+			// 内核线程与僵尸进程没有命令行。
+			continue
+		}
+		exe, _ := os.Readlink(filepath.Join(dir, "exe"))
+		exe = strings.TrimSuffix(exe, " (deleted)")
+		matched := exe != "" && targets[exe]
+		if !matched && exe == "" {
+			// 无权读取 exe 时退回比较 argv[0]。
+			matched = targets[filepath.Clean(cmdline[0])]
+		}
+		if !matched {
+			continue
+		}
+		if stat, err := os.ReadFile(filepath.Join(dir, "stat")); err == nil {
+			if idx := bytes.LastIndexByte(stat, ')'); idx >= 0 && idx+2 < len(stat) && stat[idx+2] == 'Z' {
+				continue
+			}
+		}
+		found = append(found, Process{
+			PID:         pid,
+			Exe:         exe,
+			Cmdline:     cmdline,
+			MatchesHint: hint != "" && cmdlineMentions(cmdline, hint),
+		})
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].MatchesHint != found[j].MatchesHint {
+			return found[i].MatchesHint
+		}
+		return found[i].PID < found[j].PID
+	})
+	return found
+}
+
+// Candidates 返回可接管的进程：hint 非空时只保留命令行引用 hint 的进程，
+// 同一二进制以其他配置运行的实例不属于该服务。
+func Candidates(procs []Process, hint string) []Process {
+	if hint == "" {
+		return procs
+	}
+	var matched []Process
+	for _, proc := range procs {
+		if proc.MatchesHint {
+			matched = append(matched, proc)
+		}
+	}
+	return matched
+}
+
+func readCmdline(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	data = bytes.TrimRight(data, "\x00")
+	if len(data) == 0 {
+		return nil
+	}
+	return strings.Split(string(data), "\x00")
+}
+
+// cmdlineMentions 判断命令行参数是否引用 hint（完全相同或以 hint 所在目录为参数）。
+func cmdlineMentions(cmdline []string, hint string) bool {
+	hint = filepath.Clean(hint)
+	dir := filepath.Dir(hint)
+	for _, arg := range cmdline[1:] {
+		if value, ok := strings.CutPrefix(arg, "--"); ok {
+			if _, v, found := strings.Cut(value, "="); found {
+				arg = v
+			}
+		}
+		if arg == "" || !strings.Contains(arg, "/") {
+			continue
+		}
+		arg = filepath.Clean(arg)
+		if arg == hint || arg == dir {
+			return true
+		}
+	}
+	return false
+}
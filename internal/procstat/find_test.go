@@ -0,0 +1,54 @@
+package procstat
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeProc 在 root 下伪造 /proc/<pid> 的 exe 与 cmdline。
+func fakeProc(t *testing.T, root string, pid int, exe string, args ...string) {
+	t.Helper()
+	dir := filepath.Join(root, strconv.Itoa(pid))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(exe, filepath.Join(dir, "exe")); err != nil {
+		t.Fatal(err)
+	}
+	cmdline := strings.Join(append([]string{exe}, args...), "\x00") + "\x00"
+	if err := os.WriteFile(filepath.Join(dir, "cmdline"), []byte(cmdline), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindCandidatesByHint(t *testing.T) {
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "mosdns")
+	if err := os.WriteFile(bin, nil, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(dir, "proc")
+	fakeProc(t, root, 100, bin, "start", "-d", "/srv/other")
+	fakeProc(t, root, 200, bin, "start", "-c", "/etc/herobox/mosdns/config.yaml")
+	fakeProc(t, root, 300, bin, "start", "-d", "/etc/herobox/mosdns")
+	fakeProc(t, root, 400, "/usr/bin/other", "-c", "/etc/herobox/mosdns/config.yaml")
+
+	hint := "/etc/herobox/mosdns/config.yaml"
+	found := Find(root, []string{bin}, hint)
+	if len(found) != 3 {
+		t.Fatalf("Find = %+v, want 3 processes of %s", found, bin)
+	}
+	var pids []int
+	for _, proc := range Candidates(found, hint) {
+		pids = append(pids, proc.PID)
+	}
+	if len(pids) != 2 || pids[0] != 200 || pids[1] != 300 {
+		t.Fatalf("Candidates = %v, want [200 300]", pids)
+	}
+	if got := Candidates(found, ""); len(got) != 3 {
+		t.Fatalf("Candidates without hint = %+v, want all", got)
+	}
+}
@@ -0,0 +1,108 @@
+package procstat
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks 为 Linux 的 USER_HZ，绝大多数发行版固定为 100。
+const clockTicks = 100
+
+// Usage 是一次读取 /proc/<pid> 得到的原始数据。
+type Usage struct {
+	PID      int
+	CPUTicks uint64 // utime + stime
+	RSSBytes uint64
+	Threads  int
+	FDs      int
+}
+
+// ReadUsage 读取 /proc/<pid>/stat、status 与 fd 目录。
+func ReadUsage(procRoot string, pid int) (Usage, error) {
+	if procRoot == "" {
+		procRoot = "/proc"
+	}
+	if pid <= 0 {
+		return Usage{}, fmt.Errorf("无效的 PID %d", pid)
+	}
+	dir := filepath.Join(procRoot, strconv.Itoa(pid))
+	usage := Usage{PID: pid}
+
+	stat, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return Usage{}, err
+	}
+	ticks, threads, err := parseStat(stat)
+	if err != nil {
+		return Usage{}, err
+	}
+	usage.CPUTicks = ticks
+	usage.Threads = threads
+
+	if status, err := os.ReadFile(filepath.Join(dir, "status")); err == nil {
+		usage.RSSBytes = parseRSS(status)
+	}
+	// fd 目录通常需要与目标进程相同的用户或 root 权限，读取失败时保留 0。
+	if entries, err := os.ReadDir(filepath.Join(dir, "fd")); err == nil {
+		usage.FDs = len(entries)
+	}
+	return usage, nil
+}
+
+// parseStat 解析 utime/stime 与 num_threads，进程名可能包含空格，因此从最后一个 ')' 之后切分。
+func parseStat(data []byte) (uint64, int, error) {
+	idx := bytes.LastIndexByte(data, ')')
+	if idx < 0 {
+		return 0, 0, fmt.Errorf("无法解析 stat")
+	}
+	fields := strings.Fields(string(data[idx+1:]))
+	// fields[0] 为第 3 列 state，utime/stime/num_threads 分别为第 14/15/20 列。
+	if len(fields) < 18 {
+		return 0, 0, fmt.Errorf("stat 字段不足")
+	}
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	threads, _ := strconv.Atoi(fields[17])
+	return utime + stime, threads, nil
+}
+
+func parseRSS(data []byte) uint64 {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok || key != "VmRSS" {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			return 0
+		}
+		kb, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return 0
+		}
+		return kb * 1024
+	}
+	return 0
+}
+
+// cpuPercent 根据两次采样的 tick 差值计算 CPU 占用（单核 100%）。
+func cpuPercent(prev, cur uint64, elapsed time.Duration) float64 {
+	if cur < prev || elapsed <= 0 {
+		return 0
+	}
+	seconds := float64(cur-prev) / clockTicks
+	return seconds / elapsed.Seconds() * 100
+}
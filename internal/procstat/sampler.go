@@ -0,0 +1,152 @@
+package procstat
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sample 是某一时刻的进程资源占用。
+type Sample struct {
+	Time       time.Time `json:"time"`
+	PID        int       `json:"pid"`
+	CPUPercent float64   `json:"cpuPercent"`
+	RSSBytes   uint64    `json:"rssBytes"`
+	Threads    int       `json:"threads"`
+	FDs        int       `json:"fds"`
+}
+
+// ring 是固定容量的环形缓冲。
+type ring struct {
+	samples []Sample
+	next    int
+	full    bool
+}
+
+func (r *ring) add(s Sample) {
+	r.samples[r.next] = s
+	r.next = (r.next + 1) % len(r.samples)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+func (r *ring) list(since time.Time) []Sample {
+	var ordered []Sample
+	if r.full {
+		ordered = append(ordered, r.samples[r.next:]...)
+	}
+	ordered = append(ordered, r.samples[:r.next]...)
+	out := make([]Sample, 0, len(ordered))
+	for _, s := range ordered {
+		if !s.Time.Before(since) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+type cpuMark struct {
+	pid   int
+	ticks uint64
+	at    time.Time
+}
+
+// Sampler 周期性采集各服务进程的资源占用，并为每个服务保留最近 Retention 时长的样本。
+type Sampler struct {
+	Interval  time.Duration
+	Retention time.Duration
+	ProcRoot  string
+
+	mu      sync.RWMutex
+	history map[string]*ring
+	last    map[string]cpuMark
+}
+
+// NewSampler 创建采样器，默认每 10 秒采样一次，保留 1 小时。
+func NewSampler(interval, retention time.Duration) *Sampler {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	if retention <= 0 {
+		retention = time.Hour
+	}
+	return &Sampler{
+		Interval:  interval,
+		Retention: retention,
+		ProcRoot:  "/proc",
+		history:   make(map[string]*ring),
+		last:      make(map[string]cpuMark),
+	}
+}
+
+// Run 按 Interval 调用 resolve 获取 服务名 -> PID，并采样直至 ctx 结束。
+func (s *Sampler) Run(ctx context.Context, resolve func(ctx context.Context) map[string]int) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		s.Collect(resolve(ctx))
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Collect 对给定的 PID 集合采样一次，PID <= 0 的服务会重置 CPU 基线。
+func (s *Sampler) Collect(pids map[string]int) {
+	now := time.Now()
+	for name, pid := range pids {
+		key := strings.ToLower(name)
+		if pid <= 0 {
+			s.mu.Lock()
+			delete(s.last, key)
+			s.mu.Unlock()
+			continue
+		}
+		usage, err := ReadUsage(s.ProcRoot, pid)
+		if err != nil {
+			continue
+		}
+		s.record(key, usage, now)
+	}
+}
+
+func (s *Sampler) record(key string, usage Usage, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sample := Sample{
+		Time:     now,
+		PID:      usage.PID,
+		RSSBytes: usage.RSSBytes,
+		Threads:  usage.Threads,
+		FDs:      usage.FDs,
+	}
+	if prev, ok := s.last[key]; ok && prev.pid == usage.PID {
+		sample.CPUPercent = cpuPercent(prev.ticks, usage.CPUTicks, now.Sub(prev.at))
+	}
+	s.last[key] = cpuMark{pid: usage.PID, ticks: usage.CPUTicks, at: now}
+	r, ok := s.history[key]
+	if !ok {
+		capacity := int(s.Retention/s.Interval) + 1
+		r = &ring{samples: make([]Sample, capacity)}
+		s.history[key] = r
+	}
+	r.add(sample)
+}
+
+// History 返回服务在 window 时间窗口内的样本，window <= 0 时返回全部保留样本。
+func (s *Sampler) History(name string, window time.Duration) []Sample {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.history[strings.ToLower(name)]
+	if !ok {
+		return []Sample{}
+	}
+	if window <= 0 || window > s.Retention {
+		window = s.Retention
+	}
+	return r.list(time.Now().Add(-window))
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// Backend 抽象服务的实际控制方式（systemd、直接执行或内存模拟）。
+type Backend interface {
+	// Name 返回后端标识，会写入快照的 backend 字段。
+	Name() string
+	Start(ctx context.Context, spec ServiceSpec) error
+	Stop(ctx context.Context, spec ServiceSpec) error
+	Restart(ctx context.Context, spec ServiceSpec) error
+	// Status 返回完整快照，包括开机自启与运行时信息。
+	Status(ctx context.Context, spec ServiceSpec) (Snapshot, error)
+	Enable(ctx context.Context, spec ServiceSpec, enabled bool) error
+}
+
+// DefaultBackend 仅在设置 HEROBOX_DRY_RUN=true 时返回内存模拟器；否则检测到 systemctl 时返回 systemd 后端，
+// 未找到 systemctl 时返回一个对所有操作报错的后端，避免在真实环境里静默地“启动”模拟进程。
+func DefaultBackend() Backend {
+	if os.Getenv("HEROBOX_DRY_RUN") == "true" {
+		return NewSimulator()
+	}
+	if _, err := exec.LookPath("systemctl"); err != nil {
+		return unavailableBackend{reason: "未找到 systemctl，请为服务设置 mode: exec 直接拉起，或设置 HEROBOX_DRY_RUN=true 使用模拟器"}
+	}
+	return SystemdBackend{}
+}
+
+// unavailableBackend 在没有可用控制方式时使用，所有操作都返回 reason。
+type unavailableBackend struct {
+	reason string
+}
+
+func (b unavailableBackend) Name() string { return "unavailable" }
+
+func (b unavailableBackend) err(spec ServiceSpec) error {
+	return fmt.Errorf("%s 无法控制: %s", spec.Name, b.reason)
+}
+
+func (b unavailableBackend) Start(_ context.Context, spec ServiceSpec) error   { return b.err(spec) }
+func (b unavailableBackend) Stop(_ context.Context, spec ServiceSpec) error    { return b.err(spec) }
+func (b unavailableBackend) Restart(_ context.Context, spec ServiceSpec) error { return b.err(spec) }
+
+func (b unavailableBackend) Status(_ context.Context, spec ServiceSpec) (Snapshot, error) {
+	return Snapshot{}, b.err(spec)
+}
+
+func (b unavailableBackend) Enable(_ context.Context, spec ServiceSpec, _ bool) error {
+	return b.err(spec)
+}
+
+// ServiceHooks 是直接执行后端的驱动函数，由调用方实现进程的拉起与看护。
+type ServiceHooks struct {
+	Start   func(ctx context.Context, spec ServiceSpec) error
+	Stop    func(ctx context.Context, spec ServiceSpec) error
+	Restart func(ctx context.Context, spec ServiceSpec) error
+	Status  func(ctx context.Context, spec ServiceSpec) (Status, error)
+	// Annotate 可在返回快照前补充额外信息（例如看护重启次数）。
+	Annotate func(spec ServiceSpec, snap *Snapshot)
+	// SetEnabled / Enabled 控制与查询开机自启。
+	SetEnabled func(ctx context.Context, spec ServiceSpec, enabled bool) error
+	Enabled    func(ctx context.Context, spec ServiceSpec) (bool, error)
+}
+
+// execBackend 通过 ServiceHooks 直接拉起核心进程。
+type execBackend struct {
+	hooks ServiceHooks
+}
+
+// NewExecBackend 使用给定的驱动函数创建直接执行后端。
+func NewExecBackend(hooks ServiceHooks) Backend {
+	return execBackend{hooks: hooks}
+}
+
+func (b execBackend) Name() string { return "exec" }
+
+func (b execBackend) Start(ctx context.Context, spec ServiceSpec) error {
+	if b.hooks.Start == nil {
+		return fmt.Errorf("%s 不支持 start", spec.Name)
+	}
+	return b.hooks.Start(ctx, spec)
+}
+
+func (b execBackend) Stop(ctx context.Context, spec ServiceSpec) error {
+	if b.hooks.Stop == nil {
+		return fmt.Errorf("%s 不支持 stop", spec.Name)
+	}
+	return b.hooks.Stop(ctx, spec)
+}
+
+func (b execBackend) Restart(ctx context.Context, spec ServiceSpec) error {
+	if b.hooks.Restart != nil {
+		return b.hooks.Restart(ctx, spec)
+	}
+	if err := b.Stop(ctx, spec); err != nil {
+		return err
+	}
+	return b.Start(ctx, spec)
+}
+
+func (b execBackend) Status(ctx context.Context, spec ServiceSpec) (Snapshot, error) {
+	snap := Snapshot{Name: spec.Name, Unit: spec.Unit, Status: StatusUnknown, LastUpdated: time.Now()}
+	if b.hooks.Status != nil {
+		status, err := b.hooks.Status(ctx, spec)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		snap.Status = status
+	}
+	if b.hooks.Enabled != nil {
+		if enabled, err := b.hooks.Enabled(ctx, spec); err == nil {
+			snap.Enabled = &enabled
+		}
+	}
+	if b.hooks.Annotate != nil {
+		b.hooks.Annotate(spec, &snap)
+	}
+	return snap, nil
+}
+
+func (b execBackend) Enable(ctx context.Context, spec ServiceSpec, enabled bool) error {
+	if b.hooks.SetEnabled == nil {
+		return fmt.Errorf("%s 不支持开机自启设置", spec.Name)
+	}
+	return b.hooks.SetEnabled(ctx, spec, enabled)
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDefaultBackendWithoutSystemctl(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	t.Setenv("HEROBOX_DRY_RUN", "")
+
+	backend := DefaultBackend()
+	if backend.Name() != "unavailable" {
+		t.Fatalf("backend = %s, want unavailable", backend.Name())
+	}
+	mgr := NewManagerWithBackend([]ServiceSpec{{Name: "mosdns", Unit: "mosdns.service"}}, backend)
+	mgr.Events = NewEventLog(t.TempDir(), 0)
+	if err := mgr.Start(context.Background(), "mosdns"); err == nil {
+		t.Fatal("start succeeded without systemctl")
+	}
+	if _, err := mgr.Status(context.Background(), "mosdns"); err == nil {
+		t.Fatal("status succeeded without systemctl")
+	}
+	snaps := mgr.List(context.Background())
+	if len(snaps) != 1 || snaps[0].Status != StatusUnknown || snaps[0].Error == "" || snaps[0].Backend != "unavailable" {
+		t.Fatalf("snapshots = %+v, want unknown with error", snaps)
+	}
+}
+
+func TestDefaultBackendDryRun(t *testing.T) {
+	dir := t.TempDir()
+	// 即使存在 systemctl，HEROBOX_DRY_RUN=true 也应使用模拟器。
+	if err := os.WriteFile(filepath.Join(dir, "systemctl"), []byte("#!/bin/sh\nexit 0\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+
+	t.Setenv("HEROBOX_DRY_RUN", "true")
+	if name := DefaultBackend().Name(); name != "simulator" {
+		t.Fatalf("dry run backend = %s, want simulator", name)
+	}
+	t.Setenv("HEROBOX_DRY_RUN", "")
+	if name := DefaultBackend().Name(); name != "systemd" {
+		t.Fatalf("backend = %s, want systemd", name)
+	}
+}
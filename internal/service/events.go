@@ -0,0 +1,232 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/herozmy/herobox/internal/logs"
+)
+
+// 事件的触发来源。
+const (
+	CauseAPI      = "api"      // 通过 HTTP API 操作
+	CauseBoot     = "boot"     // HeroBox 启动时的自启
+	CauseWatchdog = "watchdog" // 看护进程的崩溃检测与自动重启
+	CauseRollback = "rollback" // 安全重启失败后回滚配置并重启
+	CauseObserved = "observed" // 状态查询时发现的变化，来源不明（如外部 systemctl 或进程崩溃）
+)
+
+// 事件类型。
+const (
+	EventAction     = "action"     // start/stop/restart/enable/disable 操作
+	EventTransition = "transition" // 状态查询发现的状态变化
+	EventCrash      = "crash"      // 看护进程意外退出
+	EventRestart    = "restart"    // 看护自动重启
+	EventGaveUp     = "gave-up"    // 崩溃过于频繁，放弃自动重启
+)
+
+// Event 是一条服务事件记录。
+type Event struct {
+	Time    time.Time `json:"time"`
+	Service string    `json:"service"`
+	Kind    string    `json:"kind"`
+	Action  string    `json:"action,omitempty"`
+	Cause   string    `json:"cause,omitempty"`
+	From    Status    `json:"from,omitempty"`
+	To      Status    `json:"to,omitempty"`
+	PID     int       `json:"pid,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Detail  string    `json:"detail,omitempty"`
+}
+
+type causeKey struct{}
+
+// WithCause 在 ctx 上标记后续操作的触发来源，Manager 记录操作事件时读取。
+func WithCause(ctx context.Context, cause string) context.Context {
+	return context.WithValue(ctx, causeKey{}, cause)
+}
+
+// CauseFrom 返回 ctx 上标记的触发来源，未标记时返回 fallback。
+func CauseFrom(ctx context.Context, fallback string) string {
+	if cause, ok := ctx.Value(causeKey{}).(string); ok && cause != "" {
+		return cause
+	}
+	return fallback
+}
+
+// EventLog 按服务保存只追加的事件记录，持久化为 dir/<服务名>.jsonl。
+// 每个服务在内存中保留最近 limit 条；文件行数超过 2*limit 时压缩为最近 limit 条。
+// 零值不可用，nil *EventLog 的方法均为空操作。
+type EventLog struct {
+	dir   string
+	limit int
+
+	mu     sync.Mutex
+	byName map[string]*eventBuffer
+}
+
+type eventBuffer struct {
+	events    []Event
+	fileLines int
+}
+
+// NewEventLog 创建事件日志，limit <= 0 时每个服务保留 500 条。
+func NewEventLog(dir string, limit int) *EventLog {
+	if limit <= 0 {
+		limit = 500
+	}
+	return &EventLog{dir: dir, limit: limit, byName: make(map[string]*eventBuffer)}
+}
+
+// Record 追加一条事件；写盘失败只记录日志，不影响服务操作。
+func (l *EventLog) Record(ev Event) {
+	if l == nil || ev.Service == "" {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	key := strings.ToLower(ev.Service)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	buf := l.bufferLocked(key)
+	buf.events = append(buf.events, ev)
+	if extra := len(buf.events) - l.limit; extra > 0 {
+		buf.events = append([]Event(nil), buf.events[extra:]...)
+	}
+	var err error
+	if buf.fileLines+1 > 2*l.limit {
+		err = l.rewriteLocked(key, buf)
+	} else {
+		err = l.appendLocked(key, ev)
+		if err == nil {
+			buf.fileLines++
+		}
+	}
+	if err != nil {
+		logs.Errorf("[service] 写入 %s 事件日志失败: %v", ev.Service, err)
+	}
+}
+
+// Query 按时间顺序返回 [since, until] 内的事件，零值表示不限；limit > 0 时只保留最近的 limit 条。
+func (l *EventLog) Query(name string, since, until time.Time, limit int) []Event {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	buf := l.bufferLocked(strings.ToLower(name))
+	events := make([]Event, 0, len(buf.events))
+	for _, ev := range buf.events {
+		if !since.IsZero() && ev.Time.Before(since) {
+			continue
+		}
+		if !until.IsZero() && ev.Time.After(until) {
+			continue
+		}
+		events = append(events, ev)
+	}
+	if limit > 0 && len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+	return events
+}
+
+func (l *EventLog) path(key string) string {
+	return filepath.Join(l.dir, key+".jsonl")
+}
+
+// bufferLocked 返回服务的内存缓冲，首次访问时从文件加载，跳过无法解析的行。
+func (l *EventLog) bufferLocked(key string) *eventBuffer {
+	if buf, ok := l.byName[key]; ok {
+		return buf
+	}
+	buf := &eventBuffer{}
+	l.byName[key] = buf
+	data, err := os.ReadFile(l.path(key))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logs.Errorf("[service] 读取 %s 事件日志失败: %v", key, err)
+		}
+		return buf
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		buf.fileLines++
+		var ev Event
+		if json.Unmarshal(line, &ev) == nil {
+			buf.events = append(buf.events, ev)
+		}
+	}
+	if extra := len(buf.events) - l.limit; extra > 0 {
+		buf.events = buf.events[extra:]
+	}
+	return buf
+}
+
+func (l *EventLog) appendLocked(key string, ev Event) error {
+	line, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(l.dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path(key), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// rewriteLocked 用内存中的最近事件原子替换文件。
+func (l *EventLog) rewriteLocked(key string, buf *eventBuffer) error {
+	var data bytes.Buffer
+	for _, ev := range buf.events {
+		line, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+		data.Write(line)
+		data.WriteByte('\n')
+	}
+	if err := os.MkdirAll(l.dir, 0o755); err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(l.dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := temp.Write(data.Bytes()); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+	if err := os.Rename(temp.Name(), l.path(key)); err != nil {
+		os.Remove(temp.Name())
+		return fmt.Errorf("替换事件日志失败: %w", err)
+	}
+	buf.fileLines = len(buf.events)
+	return nil
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// GroupResult 记录批量操作中单个服务的执行结果。
+type GroupResult struct {
+	Name    string `json:"name"`
+	Action  string `json:"action"`
+	OK      bool   `json:"ok"`
+	Skipped bool   `json:"skipped,omitempty"`
+	Note    string `json:"note,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// CheckOutput 为启动前配置检查未通过时的检查输出。
+	CheckOutput string    `json:"checkOutput,omitempty"`
+	Status      *Snapshot `json:"status,omitempty"`
+}
+
+// Order 按 After/Requires 返回服务的拓扑顺序，依赖在前；同层按名称排序以保证结果稳定。
+// After 指向未注册的服务时忽略，存在循环依赖时返回错误。
+func (m *Manager) Order() ([]ServiceSpec, error) {
+	m.mu.RLock()
+	specs := make(map[string]ServiceSpec, len(m.specs))
+	for key, spec := range m.specs {
+		specs[key] = spec
+	}
+	m.mu.RUnlock()
+
+	indegree := make(map[string]int, len(specs))
+	dependents := make(map[string][]string, len(specs))
+	for key := range specs {
+		indegree[key] = 0
+	}
+	for key, spec := range specs {
+		for _, dep := range dependencies(spec) {
+			if _, ok := specs[dep]; !ok || dep == key {
+				continue
+			}
+			indegree[key]++
+			dependents[dep] = append(dependents[dep], key)
+		}
+	}
+
+	var ready []string
+	for key, degree := range indegree {
+		if degree == 0 {
+			ready = append(ready, key)
+		}
+	}
+	ordered := make([]ServiceSpec, 0, len(specs))
+	for len(ready) > 0 {
+		slices.Sort(ready)
+		key := ready[0]
+		ready = ready[1:]
+		ordered = append(ordered, specs[key])
+		for _, next := range dependents[key] {
+			indegree[next]--
+			if indegree[next] == 0 {
+				ready = append(ready, next)
+			}
+		}
+	}
+	if len(ordered) != len(specs) {
+		var cycle []string
+		for key, degree := range indegree {
+			if degree > 0 {
+				cycle = append(cycle, key)
+			}
+		}
+		slices.Sort(cycle)
+		return nil, fmt.Errorf("服务存在循环依赖: %s", strings.Join(cycle, ", "))
+	}
+	return ordered, nil
+}
+
+// dependencies 返回 After 与 Requires 的并集（小写、去重）。
+func dependencies(spec ServiceSpec) []string {
+	var deps []string
+	for _, dep := range append(append([]string(nil), spec.After...), spec.Requires...) {
+		dep = strings.ToLower(strings.TrimSpace(dep))
+		if dep != "" && !slices.Contains(deps, dep) {
+			deps = append(deps, dep)
+		}
+	}
+	return deps
+}
+
+// StartAll 按依赖顺序启动设置了开机自启（systemd enabled 或 herobox.yaml autostart）的服务，
+// 其余服务跳过，避免同时拉起 sing-box 与 mihomo 这类互斥的核心；Requires 的依赖未能启动时跳过该服务。
+func (m *Manager) StartAll(ctx context.Context) ([]GroupResult, error) {
+	return m.runGroup(ctx, "start", false, m.Start, notEnabled)
+}
+
+// StopAll 按依赖的逆序停止全部服务，单个失败不会中断后续服务。
+func (m *Manager) StopAll(ctx context.Context) ([]GroupResult, error) {
+	return m.runGroup(ctx, "stop", true, m.Stop, nil)
+}
+
+// RestartAll 按依赖顺序逐个重启正在运行的服务；restart 会拉起已停止的服务，因此未运行的服务跳过，
+// 避免同时拉起互斥的核心。Requires 的依赖未能重启时跳过该服务。
+func (m *Manager) RestartAll(ctx context.Context) ([]GroupResult, error) {
+	return m.runGroup(ctx, "restart", false, m.Restart, notActive)
+}
+
+// runGroup 按顺序执行 run。每个服务在执行前查询一次状态，供 alreadyDone 与 exclude 共用；
+// exclude 非 nil 时对其返回非空说明的服务跳过执行，依赖它的服务视为依赖不可用。
+func (m *Manager) runGroup(ctx context.Context, action string, reverse bool, run func(context.Context, string) error, exclude func(Snapshot, error) string) ([]GroupResult, error) {
+	ordered, err := m.Order()
+	if err != nil {
+		return nil, err
+	}
+	if reverse {
+		slices.Reverse(ordered)
+	}
+	registered := make(map[string]bool, len(ordered))
+	for _, spec := range ordered {
+		registered[strings.ToLower(spec.Name)] = true
+	}
+
+	failed := make(map[string]bool)
+	results := make([]GroupResult, 0, len(ordered))
+	for _, spec := range ordered {
+		key := strings.ToLower(spec.Name)
+		result := GroupResult{Name: spec.Name, Action: action}
+		if !reverse {
+			if dep := unmetRequirement(spec, registered, failed); dep != "" {
+				failed[key] = true
+				result.Skipped = true
+				result.Error = fmt.Sprintf("依赖 %s 不可用，已跳过", dep)
+				logService(ctx, spec, "warn", "%s %s 已跳过：依赖 %s 不可用", action, spec.Name, dep)
+				results = append(results, result)
+				continue
+			}
+		}
+		snap, statusErr := m.Status(ctx, spec.Name)
+		acted := false
+		if note := alreadyDone(snap, statusErr, action); note != "" {
+			result.OK = true
+			result.Note = note
+		} else if note := excludedNote(exclude, snap, statusErr); note != "" {
+			failed[key] = true
+			result.OK = true
+			result.Skipped = true
+			result.Note = note
+		} else {
+			acted = true
+			if err := run(ctx, spec.Name); err != nil {
+				failed[key] = true
+				result.Error = err.Error()
+				var checkErr *CheckError
+				if errors.As(err, &checkErr) {
+					result.CheckOutput = checkErr.Output
+				}
+			} else {
+				result.OK = true
+			}
+		}
+		if acted {
+			snap, statusErr = m.Status(ctx, spec.Name)
+		}
+		if statusErr == nil {
+			result.Status = &snap
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// alreadyDone 判断服务是否已处于目标状态：启动时已在运行、停止时未在运行均视为成功。
+func alreadyDone(snap Snapshot, err error, action string) string {
+	if err != nil {
+		return ""
+	}
+	switch {
+	case action == "start" && snap.Status.Active():
+		return "已在运行"
+	case action == "stop" && (snap.Status == StatusStopped || snap.Status == StatusMissing):
+		return "未在运行"
+	}
+	return ""
+}
+
+func excludedNote(exclude func(Snapshot, error) string, snap Snapshot, err error) string {
+	if exclude == nil {
+		return ""
+	}
+	return exclude(snap, err)
+}
+
+// notEnabled 对未设置开机自启的服务返回跳过说明。
+func notEnabled(snap Snapshot, err error) string {
+	if err != nil {
+		return fmt.Sprintf("查询状态失败，已跳过: %v", err)
+	}
+	if snap.Enabled == nil || !*snap.Enabled {
+		return "未设置开机自启，已跳过"
+	}
+	return ""
+}
+
+// notActive 对未在运行的服务返回跳过说明。
+func notActive(snap Snapshot, err error) string {
+	if err != nil {
+		return fmt.Sprintf("查询状态失败，已跳过: %v", err)
+	}
+	if !snap.Status.Active() {
+		return "未在运行，已跳过"
+	}
+	return ""
+}
+
+// unmetRequirement 返回第一个未注册或已失败的 Requires 依赖。
+func unmetRequirement(spec ServiceSpec, registered, failed map[string]bool) string {
+	for _, dep := range spec.Requires {
+		dep = strings.ToLower(strings.TrimSpace(dep))
+		if dep == "" {
+			continue
+		}
+		if !registered[dep] || failed[dep] {
+			return dep
+		}
+	}
+	return ""
+}
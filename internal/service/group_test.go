@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStartAllSkipsServicesWithoutAutostart(t *testing.T) {
+	sim := NewSimulator()
+	mgr := NewManagerWithBackend([]ServiceSpec{
+		{Name: "sing-box", Unit: "sing-box.service", Backend: sim},
+		{Name: "mihomo", Unit: "mihomo.service", Backend: sim},
+		{Name: "mosdns", Unit: "mosdns.service", Backend: sim, After: []string{"sing-box", "mihomo"}},
+		{Name: "ui", Unit: "ui.service", Backend: sim, Requires: []string{"mihomo"}},
+	}, sim)
+	ctx := context.Background()
+	for _, name := range []string{"sing-box", "mosdns", "ui"} {
+		if err := mgr.Enable(ctx, name, true); err != nil {
+			t.Fatalf("enable %s: %v", name, err)
+		}
+	}
+
+	results, err := mgr.StartAll(ctx)
+	if err != nil {
+		t.Fatalf("StartAll: %v", err)
+	}
+	got := make(map[string]GroupResult, len(results))
+	for _, r := range results {
+		got[r.Name] = r
+	}
+	if r := got["mihomo"]; !r.Skipped || r.Note != "未设置开机自启，已跳过" {
+		t.Errorf("mihomo: got %+v, want skipped without autostart", r)
+	}
+	if r := got["ui"]; !r.Skipped || r.OK {
+		t.Errorf("ui: got %+v, want skipped because mihomo was not started", r)
+	}
+	for _, name := range []string{"sing-box", "mosdns"} {
+		if r := got[name]; !r.OK || r.Skipped {
+			t.Errorf("%s: got %+v, want started", name, r)
+		}
+	}
+	for name, want := range map[string]Status{"sing-box": StatusRunning, "mosdns": StatusRunning, "mihomo": StatusStopped, "ui": StatusStopped} {
+		snap, err := mgr.Status(ctx, name)
+		if err != nil {
+			t.Fatalf("status %s: %v", name, err)
+		}
+		if snap.Status != want {
+			t.Errorf("%s: status %s, want %s", name, snap.Status, want)
+		}
+	}
+}
+
+func TestRestartAllSkipsStoppedServices(t *testing.T) {
+	sim := NewSimulator()
+	mgr := NewManagerWithBackend([]ServiceSpec{
+		{Name: "sing-box", Unit: "sing-box.service", Backend: sim},
+		{Name: "mihomo", Unit: "mihomo.service", Backend: sim},
+		{Name: "mosdns", Unit: "mosdns.service", Backend: sim, After: []string{"sing-box", "mihomo"}},
+	}, sim)
+	ctx := context.Background()
+	for _, name := range []string{"sing-box", "mosdns"} {
+		if err := mgr.Start(ctx, name); err != nil {
+			t.Fatalf("start %s: %v", name, err)
+		}
+	}
+	before, _ := mgr.Status(ctx, "sing-box")
+
+	results, err := mgr.RestartAll(ctx)
+	if err != nil {
+		t.Fatalf("RestartAll: %v", err)
+	}
+	got := make(map[string]GroupResult, len(results))
+	for _, r := range results {
+		got[r.Name] = r
+	}
+	if r := got["mihomo"]; !r.Skipped || r.Note != "未在运行，已跳过" || r.Status == nil || r.Status.Status != StatusStopped {
+		t.Errorf("mihomo: got %+v, want skipped and still stopped", r)
+	}
+	for _, name := range []string{"sing-box", "mosdns"} {
+		if r := got[name]; !r.OK || r.Skipped || r.Status == nil || r.Status.Status != StatusRunning {
+			t.Errorf("%s: got %+v, want restarted", name, r)
+		}
+	}
+	if after := got["sing-box"].Status; after.PID == before.PID {
+		t.Errorf("sing-box PID unchanged (%d), want a restart", after.PID)
+	}
+	if snap, _ := mgr.Status(ctx, "mihomo"); snap.Status != StatusStopped {
+		t.Errorf("mihomo status %s after RestartAll, want stopped", snap.Status)
+	}
+}
@@ -5,11 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"os/exec"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/herozmy/herobox/internal/dnsprobe"
 	"github.com/herozmy/herobox/internal/logs"
 )
 
@@ -21,14 +22,64 @@ const (
 	StatusStopped Status = "stopped"
 	StatusUnknown Status = "unknown"
 	StatusMissing Status = "missing"
+	StatusFailed  Status = "failed"
+	// StatusDegraded 表示进程在运行但健康探测未通过（如 DNS 查询失败或超过延迟阈值）。
+	StatusDegraded Status = "degraded"
+	// StatusStarting 表示 systemd unit 正在启动（ActiveState=activating 且 SubState 为 start*）。
+	StatusStarting Status = "starting"
 )
 
+// Active 表示进程处于运行中，包含健康探测未通过的 degraded。
+func (s Status) Active() bool {
+	return s == StatusRunning || s == StatusDegraded
+}
+
 // ServiceSpec 定义一个受控服务。
 type ServiceSpec struct {
-	Name        string   // 业务名称，例如 mosdns
-	Unit        string   // systemd unit 名称，例如 mosdns.service
-	BinaryPaths []string // 可选：对应核心二进制路径（可多备选）
-	Hooks       ServiceHooks
+	Name        string          // 业务名称，例如 mosdns
+	Unit        string          // systemd unit 名称，例如 mosdns.service
+	BinaryPaths []string        // 可选：对应核心二进制路径（可多备选）
+	Description string          // 可选：生成 systemd unit 时使用的描述
+	Args        func() []string // 可选：核心启动参数，用于直接执行与生成 unit
+	After       []string        // 可选：批量操作时需排在这些服务之后启动
+	Requires    []string        // 可选：强依赖，依赖启动失败时跳过本服务
+	Backend     Backend         // 可选：控制方式，为空时使用 Manager 的默认后端
+	// Check 可选：start/restart 前的配置检查，返回错误时拒绝操作。
+	Check func(ctx context.Context, spec ServiceSpec) error
+	// Health 可选：服务运行时的健康探测，报告不健康时状态降级为 degraded。
+	Health func(ctx context.Context, spec ServiceSpec) *HealthReport
+}
+
+// HealthReport 为一次健康探测的结果。
+type HealthReport struct {
+	CheckedAt time.Time `json:"checkedAt"`
+	Healthy   bool      `json:"healthy"`
+	Reason    string    `json:"reason,omitempty"`
+	// ThresholdMS 为判定为过慢的延迟阈值（毫秒）。
+	ThresholdMS float64           `json:"thresholdMs,omitempty"`
+	Probes      []dnsprobe.Result `json:"probes,omitempty"`
+}
+
+// CheckError 表示启动前配置检查未通过，Output 为检查程序的完整输出。
+type CheckError struct {
+	Service string
+	Command string
+	Output  string
+}
+
+func (e *CheckError) Error() string {
+	summary := ""
+	lines := strings.Split(strings.TrimSpace(e.Output), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if line := strings.TrimSpace(lines[i]); line != "" {
+			summary = line
+			break
+		}
+	}
+	if summary == "" {
+		return fmt.Sprintf("%s 配置检查未通过", e.Service)
+	}
+	return fmt.Sprintf("%s 配置检查未通过: %s", e.Service, summary)
 }
 
 // Snapshot 描述一个服务的即时状态。
@@ -38,42 +89,103 @@ type Snapshot struct {
 	Status      Status    `json:"status"`
 	LastUpdated time.Time `json:"lastUpdated"`
 	Version     string    `json:"version,omitempty"`
+	// Backend 为实际控制该服务的后端：systemd / exec / simulator / unavailable。
+	Backend string `json:"backend,omitempty"`
+	// Error 为状态查询失败的原因，此时 Status 为 unknown。
+	Error string `json:"error,omitempty"`
+	// Enabled 表示开机是否自动启动：systemd 来自 is-enabled，直接执行模式来自 herobox.yaml。
+	Enabled *bool `json:"enabled,omitempty"`
+	// PID、运行时长与资源占用来自 systemctl show 或 HeroBox 直接看护的进程。
+	PID           int        `json:"pid,omitempty"`
+	ActiveSince   *time.Time `json:"activeSince,omitempty"`
+	UptimeSeconds int64      `json:"uptimeSeconds,omitempty"`
+	MemoryBytes   uint64     `json:"memoryBytes,omitempty"`
+	CPUUsageNSec  uint64     `json:"cpuUsageNSec,omitempty"`
+	SubState      string     `json:"subState,omitempty"`
+	Result        string     `json:"result,omitempty"`
+	// Restarts 为 systemd NRestarts 或看护进程的自动重启次数。
+	Restarts int `json:"restarts,omitempty"`
+	// 以下字段仅在 HeroBox 直接看护进程时填充。
+	LastExit *ExitInfo `json:"lastExit,omitempty"`
+	Watchdog string    `json:"watchdog,omitempty"`
+	// Instances 在发现多个实例同时运行时列出全部 PID。
+	Instances []int `json:"instances,omitempty"`
+	// Health 为最近一次健康探测结果，仅声明了 Health 的服务在运行时填充。
+	Health *HealthReport `json:"health,omitempty"`
 }
 
-// ServiceHooks 允许为特定服务注入自定义驱动逻辑（例如直接执行二进制）。
-type ServiceHooks struct {
-	Start   func(ctx context.Context, spec ServiceSpec) error
-	Stop    func(ctx context.Context, spec ServiceSpec) error
-	Restart func(ctx context.Context, spec ServiceSpec) error
-	Status  func(ctx context.Context, spec ServiceSpec) (Status, error)
+// ExitInfo 记录受管进程最近一次退出的结果。
+type ExitInfo struct {
+	Code   int       `json:"code"`
+	Signal string    `json:"signal,omitempty"`
+	Error  string    `json:"error,omitempty"`
+	Time   time.Time `json:"time"`
 }
 
-// Manager 负责通过 systemctl 控制服务，若系统不支持则自动切换为内存模拟模式。
+// Manager 负责按服务声明的 Backend 控制服务，并缓存最近一次状态。
 type Manager struct {
-	specs  map[string]ServiceSpec
-	states map[string]Snapshot
-	mu     sync.RWMutex
-	useCtl bool
-	dryRun bool
+	// ProbeTimeout 为 List 中单个服务状态查询的超时，默认 3 秒。
+	ProbeTimeout time.Duration
+	// Events 可选：记录操作与状态变化的事件日志。
+	Events *EventLog
+
+	specs   map[string]ServiceSpec
+	states  map[string]Snapshot
+	mu      sync.RWMutex
+	backend Backend
+	// pending 记录正在执行操作的服务，期间状态查询观察到的变化归属于该操作，不单独记录事件。
+	pending map[string]int
 }
 
-// NewManager 创建 Manager。若未找到 systemctl 或显式设置 HEROBOX_DRY_RUN=true，则进入 dry-run。
+const defaultProbeTimeout = 3 * time.Second
+
+// NewManager 使用 DefaultBackend 作为默认后端创建 Manager。
 func NewManager(specs []ServiceSpec) *Manager {
+	return NewManagerWithBackend(specs, DefaultBackend())
+}
+
+// NewManagerWithBackend 创建 Manager，未指定 Backend 的服务使用 fallback。
+func NewManagerWithBackend(specs []ServiceSpec, fallback Backend) *Manager {
 	specMap := make(map[string]ServiceSpec, len(specs))
 	for _, spec := range specs {
 		specMap[strings.ToLower(spec.Name)] = spec
 	}
+	return &Manager{
+		specs:   specMap,
+		states:  make(map[string]Snapshot, len(specMap)),
+		backend: fallback,
+		pending: make(map[string]int),
+	}
+}
 
-	_, err := exec.LookPath("systemctl")
-	dryRun := os.Getenv("HEROBOX_DRY_RUN") == "true"
-	useCtl := err == nil && !dryRun
+// DefaultBackend 返回未指定 Backend 的服务所使用的后端。
+func (m *Manager) DefaultBackend() Backend {
+	return m.backend
+}
 
-	return &Manager{
-		specs:  specMap,
-		states: make(map[string]Snapshot, len(specMap)),
-		useCtl: useCtl,
-		dryRun: !useCtl,
+// Register 注册或替换服务定义，已缓存的状态会被清除。
+func (m *Manager) Register(spec ServiceSpec) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := strings.ToLower(spec.Name)
+	m.specs[key] = spec
+	delete(m.states, key)
+	logService(context.Background(), spec, "info", "已注册服务 %s (%s)", spec.Name, spec.Unit)
+}
+
+// Unregister 移除服务定义。
+func (m *Manager) Unregister(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := strings.ToLower(name)
+	spec, ok := m.specs[key]
+	if !ok {
+		return fmt.Errorf("service %s 未注册", name)
 	}
+	delete(m.specs, key)
+	delete(m.states, key)
+	logService(context.Background(), spec, "info", "已移除服务 %s", spec.Name)
+	return nil
 }
 
 // ensureSpec 返回服务定义。
@@ -87,92 +199,100 @@ func (m *Manager) ensureSpec(name string) (ServiceSpec, error) {
 	return spec, nil
 }
 
+// Spec 返回已注册的服务定义。
+func (m *Manager) Spec(name string) (ServiceSpec, error) {
+	return m.ensureSpec(name)
+}
+
+// BackendFor 返回服务实际使用的后端。
+func (m *Manager) BackendFor(spec ServiceSpec) Backend {
+	if spec.Backend != nil {
+		return spec.Backend
+	}
+	return m.backend
+}
+
 // Start 启动服务。
 func (m *Manager) Start(ctx context.Context, name string) error {
-	spec, err := m.ensureSpec(name)
-	if err != nil {
-		return err
-	}
-	if !m.binaryReady(spec) {
-		m.recordState(spec.Name, StatusMissing)
-		err = fmt.Errorf("%s 未安装", spec.Name)
-		logService(spec, "error", "%s start 失败：%v", spec.Name, err)
-		return err
-	}
-	if spec.Hooks.Start != nil {
-		if err := spec.Hooks.Start(ctx, spec); err != nil {
-			logService(spec, "error", "自定义 start 失败：%v", err)
-			return err
-		}
-	} else if m.useCtl {
-		if err := m.execSystemctl(ctx, "start", spec.Unit); err != nil {
-			logService(spec, "error", "systemctl start %s(%s) 失败：%v", spec.Name, spec.Unit, err)
-			return err
-		}
-	}
-	m.recordState(spec.Name, StatusRunning)
-	logService(spec, "info", "%s 已启动", spec.Name)
-	return nil
+	return m.control(ctx, name, "start", StatusRunning, "已启动", Backend.Start)
 }
 
 // Stop 停止服务。
 func (m *Manager) Stop(ctx context.Context, name string) error {
+	return m.control(ctx, name, "stop", StatusStopped, "已停止", Backend.Stop)
+}
+
+// Restart 重启服务。
+func (m *Manager) Restart(ctx context.Context, name string) error {
+	return m.control(ctx, name, "restart", StatusRunning, "已重启", Backend.Restart)
+}
+
+func (m *Manager) control(ctx context.Context, name, action string, target Status, done string, run func(Backend, context.Context, ServiceSpec) error) error {
 	spec, err := m.ensureSpec(name)
 	if err != nil {
 		return err
 	}
+	defer m.beginAction(spec.Name)()
+	ev := Event{
+		Service: spec.Name,
+		Kind:    EventAction,
+		Action:  action,
+		Cause:   CauseFrom(ctx, CauseAPI),
+		From:    m.snapshot(spec.Name).Status,
+		To:      target,
+	}
 	if !m.binaryReady(spec) {
 		m.recordState(spec.Name, StatusMissing)
 		err = fmt.Errorf("%s 未安装", spec.Name)
-		logService(spec, "error", "%s stop 失败：%v", spec.Name, err)
+		logService(ctx, spec, "error", "%s %s 失败：%v", spec.Name, action, err)
+		m.recordActionError(ev, err)
 		return err
 	}
-	if spec.Hooks.Stop != nil {
-		if err := spec.Hooks.Stop(ctx, spec); err != nil {
-			logService(spec, "error", "自定义 stop 失败：%v", err)
-			return err
-		}
-	} else if m.useCtl {
-		if err := m.execSystemctl(ctx, "stop", spec.Unit); err != nil {
-			logService(spec, "error", "systemctl stop %s(%s) 失败：%v", spec.Name, spec.Unit, err)
+	if target == StatusRunning && spec.Check != nil {
+		if err := spec.Check(ctx, spec); err != nil {
+			logService(ctx, spec, "error", "%s %s 已取消：%v", spec.Name, action, err)
+			m.recordActionError(ev, err)
 			return err
 		}
 	}
-	m.recordState(spec.Name, StatusStopped)
-	logService(spec, "info", "%s 已停止", spec.Name)
+	backend := m.BackendFor(spec)
+	if err := run(backend, ctx, spec); err != nil {
+		logService(ctx, spec, "error", "%s %s 失败（%s）：%v", spec.Name, action, backend.Name(), err)
+		m.recordActionError(ev, err)
+		return err
+	}
+	m.recordState(spec.Name, target)
+	m.Events.Record(ev)
+	logService(ctx, spec, "info", "%s %s", spec.Name, done)
 	return nil
 }
 
-// Restart 重启服务。
-func (m *Manager) Restart(ctx context.Context, name string) error {
+// recordActionError 记录失败的操作，失败时状态未变化，因此不填写 To。
+func (m *Manager) recordActionError(ev Event, err error) {
+	ev.To = ""
+	ev.Error = err.Error()
+	m.Events.Record(ev)
+}
+
+// Enable 设置服务开机自启（enabled=false 时取消）。
+func (m *Manager) Enable(ctx context.Context, name string, enabled bool) error {
 	spec, err := m.ensureSpec(name)
 	if err != nil {
 		return err
 	}
-	if !m.binaryReady(spec) {
-		m.recordState(spec.Name, StatusMissing)
-		err = fmt.Errorf("%s 未安装", spec.Name)
-		logService(spec, "error", "%s restart 失败：%v", spec.Name, err)
+	action := "disable"
+	if enabled {
+		action = "enable"
+	}
+	ev := Event{Service: spec.Name, Kind: EventAction, Action: action, Cause: CauseFrom(ctx, CauseAPI)}
+	backend := m.BackendFor(spec)
+	if err := backend.Enable(ctx, spec, enabled); err != nil {
+		logService(ctx, spec, "error", "%s %s 失败（%s）：%v", spec.Name, action, backend.Name(), err)
+		m.recordActionError(ev, err)
 		return err
 	}
-	if spec.Hooks.Restart != nil {
-		if err := spec.Hooks.Restart(ctx, spec); err != nil {
-			logService(spec, "error", "自定义 restart 失败：%v", err)
-			return err
-		}
-	} else if m.useCtl {
-		if err := m.execSystemctl(ctx, "restart", spec.Unit); err != nil {
-			logService(spec, "error", "systemctl restart %s(%s) 失败：%v", spec.Name, spec.Unit, err)
-			return err
-		}
-	} else {
-		// dry run: 直接标记为 running
-		m.recordState(spec.Name, StatusRunning)
-		logService(spec, "info", "%s restart (dry-run)", spec.Name)
-		return nil
-	}
-	m.recordState(spec.Name, StatusRunning)
-	logService(spec, "info", "%s 已重启", spec.Name)
+	m.Events.Record(ev)
+	logService(ctx, spec, "info", "%s %s 完成", spec.Name, action)
 	return nil
 }
 
@@ -182,68 +302,158 @@ func (m *Manager) Status(ctx context.Context, name string) (Snapshot, error) {
 	if err != nil {
 		return Snapshot{}, err
 	}
+	backend := m.BackendFor(spec)
 	if !m.binaryReady(spec) {
+		// 仅在状态变化时记录日志，避免后台周期性查询刷屏。
+		if m.snapshot(spec.Name).Status != StatusMissing {
+			logService(ctx, spec, "error", "%s 状态：missing（binary 未找到）", spec.Name)
+		}
+		m.observe(spec, Snapshot{Status: StatusMissing})
 		m.recordState(spec.Name, StatusMissing)
-		logService(spec, "error", "%s 状态：missing（binary 未找到）", spec.Name)
-		return m.snapshot(spec.Name), nil
+		snap := m.snapshot(spec.Name)
+		snap.Backend = backend.Name()
+		return snap, nil
 	}
-	if spec.Hooks.Status != nil {
-		status, err := spec.Hooks.Status(ctx, spec)
-		if err != nil {
-			return Snapshot{}, err
-		}
-		m.recordState(spec.Name, status)
-		return m.snapshot(spec.Name), nil
-	}
-	if m.useCtl {
-		if err := m.execSystemctl(ctx, "is-active", spec.Unit); err != nil {
-			var exitErr *exec.ExitError
-			if errors.As(err, &exitErr) {
-				m.recordState(spec.Name, StatusStopped)
-			} else {
-				return Snapshot{}, err
+	snap, err := backend.Status(ctx, spec)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	snap.Name = spec.Name
+	snap.Unit = spec.Unit
+	snap.Backend = backend.Name()
+	if snap.LastUpdated.IsZero() {
+		snap.LastUpdated = time.Now()
+	}
+	if snap.Status == StatusRunning && spec.Health != nil {
+		if report := spec.Health(ctx, spec); report != nil {
+			snap.Health = report
+			if !report.Healthy {
+				snap.Status = StatusDegraded
 			}
-		} else {
-			m.recordState(spec.Name, StatusRunning)
 		}
 	}
-
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	snap, ok := m.states[strings.ToLower(spec.Name)]
-	if !ok {
-		snap = Snapshot{Name: spec.Name, Unit: spec.Unit, Status: StatusUnknown}
+	m.observe(spec, snap)
+	if prev := m.snapshot(spec.Name).Status; prev != snap.Status {
+		switch {
+		case snap.Status == StatusDegraded:
+			logService(ctx, spec, "error", "%s 健康探测未通过：%s", spec.Name, snap.Health.Reason)
+		case prev == StatusDegraded && snap.Status == StatusRunning:
+			logService(ctx, spec, "info", "%s 健康探测已恢复", spec.Name)
+		}
 	}
+	m.recordSnapshot(snap)
 	return snap, nil
 }
 
-// List 返回所有服务状态（必要时刷新）。
-func (m *Manager) List(ctx context.Context) ([]Snapshot, error) {
+// List 并发查询所有服务状态，每个服务单独受 ProbeTimeout 限制。
+// 单个服务查询失败或超时时返回 unknown 状态并在 Error 中给出原因，不影响其他服务。
+func (m *Manager) List(ctx context.Context) []Snapshot {
 	m.mu.RLock()
 	specs := make([]ServiceSpec, 0, len(m.specs))
 	for _, spec := range m.specs {
 		specs = append(specs, spec)
 	}
 	m.mu.RUnlock()
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Name < specs[j].Name })
 
-	snaps := make([]Snapshot, 0, len(specs))
-	for _, spec := range specs {
+	snaps := make([]Snapshot, len(specs))
+	var wg sync.WaitGroup
+	for i, spec := range specs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			snaps[i] = m.probe(ctx, spec)
+		}()
+	}
+	wg.Wait()
+	return snaps
+}
+
+// probe 在独立的超时内查询单个服务；后端忽略 ctx 时也会按时返回，查询结果被丢弃。
+func (m *Manager) probe(ctx context.Context, spec ServiceSpec) Snapshot {
+	timeout := m.ProbeTimeout
+	if timeout <= 0 {
+		timeout = defaultProbeTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		snap Snapshot
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
 		snap, err := m.Status(ctx, spec.Name)
-		if err != nil {
-			return nil, err
+		done <- result{snap, err}
+	}()
+	var err error
+	select {
+	case res := <-done:
+		if res.err == nil {
+			return res.snap
 		}
-		snaps = append(snaps, snap)
+		err = res.err
+	case <-ctx.Done():
+		err = fmt.Errorf("状态查询超时（%s）", timeout)
+	}
+	if !errors.Is(err, context.Canceled) {
+		logService(ctx, spec, "error", "%s 状态查询失败：%v", spec.Name, err)
+	}
+	return Snapshot{
+		Name:        spec.Name,
+		Unit:        spec.Unit,
+		Status:      StatusUnknown,
+		LastUpdated: time.Now(),
+		Backend:     m.BackendFor(spec).Name(),
+		Error:       err.Error(),
 	}
-	return snaps, nil
 }
 
-func (m *Manager) execSystemctl(ctx context.Context, action string, unit string) error {
-	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
-	defer cancel()
-	cmd := exec.CommandContext(ctx, "systemctl", action, unit)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+// beginAction 标记服务正在执行操作，返回的函数用于结束标记。
+func (m *Manager) beginAction(name string) func() {
+	key := strings.ToLower(name)
+	m.mu.Lock()
+	m.pending[key]++
+	m.mu.Unlock()
+	return func() {
+		m.mu.Lock()
+		if m.pending[key]--; m.pending[key] <= 0 {
+			delete(m.pending, key)
+		}
+		m.mu.Unlock()
+	}
+}
+
+// observe 在状态查询发现与缓存不同的状态时记录 transition 事件；首次查询与操作进行中不记录。
+func (m *Manager) observe(spec ServiceSpec, snap Snapshot) {
+	m.mu.RLock()
+	key := strings.ToLower(spec.Name)
+	prev, known := m.states[key]
+	busy := m.pending[key] > 0
+	m.mu.RUnlock()
+	if !known || busy || prev.Status == snap.Status {
+		return
+	}
+	ev := Event{
+		Service: spec.Name,
+		Kind:    EventTransition,
+		Cause:   CauseObserved,
+		From:    prev.Status,
+		To:      snap.Status,
+		PID:     snap.PID,
+		Error:   snap.Error,
+	}
+	if snap.Status == StatusDegraded && snap.Health != nil {
+		ev.Detail = snap.Health.Reason
+	}
+	if exit := snap.LastExit; exit != nil && !snap.Status.Active() {
+		ev.Detail = fmt.Sprintf("exit code %d", exit.Code)
+		if exit.Signal != "" {
+			ev.Detail = "signal " + exit.Signal
+		}
+	}
+	m.Events.Record(ev)
 }
 
 func (m *Manager) recordState(name string, status Status) {
@@ -259,6 +469,12 @@ func (m *Manager) recordState(name string, status Status) {
 	}
 }
 
+func (m *Manager) recordSnapshot(snap Snapshot) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.states[strings.ToLower(snap.Name)] = snap
+}
+
 func (m *Manager) snapshot(name string) Snapshot {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -287,7 +503,8 @@ func (m *Manager) binaryReady(spec ServiceSpec) bool {
 	return false
 }
 
-func logService(spec ServiceSpec, level, format string, args ...any) {
+// logService 按服务写日志，ctx 携带的操作标记随日志一起发布，供进度流只转发本次操作的日志。
+func logService(ctx context.Context, spec ServiceSpec, level, format string, args ...any) {
 	prefix := "[service]"
 	if strings.EqualFold(spec.Name, "mosdns") {
 		prefix = "[mosdns]"
@@ -295,8 +512,8 @@ func logService(spec ServiceSpec, level, format string, args ...any) {
 	msg := fmt.Sprintf("%s %s", prefix, fmt.Sprintf(format, args...))
 	switch level {
 	case "error":
-		logs.Errorf("%s", msg)
+		logs.ErrorfContext(ctx, "%s", msg)
 	default:
-		logs.Infof("%s", msg)
+		logs.InfofContext(ctx, "%s", msg)
 	}
 }
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newSimManager(t *testing.T, names ...string) (*Manager, *Simulator) {
+	t.Helper()
+	sim := NewSimulator()
+	specs := make([]ServiceSpec, 0, len(names))
+	for _, name := range names {
+		specs = append(specs, ServiceSpec{Name: name, Unit: name + ".service", Backend: sim})
+	}
+	mgr := NewManagerWithBackend(specs, sim)
+	mgr.Events = NewEventLog(t.TempDir(), 0)
+	return mgr, sim
+}
+
+func mustStatus(t *testing.T, mgr *Manager, name string) Snapshot {
+	t.Helper()
+	snap, err := mgr.Status(context.Background(), name)
+	if err != nil {
+		t.Fatalf("status %s: %v", name, err)
+	}
+	return snap
+}
+
+func TestManagerStartFailure(t *testing.T) {
+	mgr, sim := newSimManager(t, "mosdns")
+	ctx := context.Background()
+	boom := errors.New("bind: address already in use")
+
+	sim.Fail("mosdns", "start", boom)
+	for range 2 {
+		if err := mgr.Start(ctx, "mosdns"); !errors.Is(err, boom) {
+			t.Fatalf("Start err = %v, want %v", err, boom)
+		}
+	}
+	snap := mustStatus(t, mgr, "mosdns")
+	if snap.Status != StatusFailed || snap.LastExit == nil || snap.LastExit.Error != boom.Error() {
+		t.Fatalf("after failed start: %+v", snap)
+	}
+	events := mgr.Events.Query("mosdns", time.Time{}, time.Time{}, 0)
+	if len(events) == 0 || events[0].Action != "start" || events[0].Error != boom.Error() || events[0].To != "" {
+		t.Fatalf("failed start event = %+v", events)
+	}
+
+	// FailNext 只影响下一次启动。
+	sim.Fail("mosdns", "start", nil)
+	sim.FailNext("mosdns", "start", boom)
+	if err := mgr.Start(ctx, "mosdns"); !errors.Is(err, boom) {
+		t.Fatalf("Start err = %v, want %v", err, boom)
+	}
+	if err := mgr.Start(ctx, "mosdns"); err != nil {
+		t.Fatalf("Start after FailNext: %v", err)
+	}
+	if snap := mustStatus(t, mgr, "mosdns"); snap.Status != StatusRunning || snap.PID == 0 {
+		t.Fatalf("after retry: %+v", snap)
+	}
+}
+
+func TestManagerStatusObservesCrash(t *testing.T) {
+	mgr, sim := newSimManager(t, "sing-box")
+	if err := mgr.Start(context.Background(), "sing-box"); err != nil {
+		t.Fatal(err)
+	}
+	if snap := mustStatus(t, mgr, "sing-box"); snap.Status != StatusRunning {
+		t.Fatalf("status = %s, want running", snap.Status)
+	}
+
+	sim.Crash("sing-box", 137)
+	snap := mustStatus(t, mgr, "sing-box")
+	if snap.Status != StatusFailed || snap.PID != 0 || snap.LastExit == nil || snap.LastExit.Code != 137 {
+		t.Fatalf("after crash: %+v", snap)
+	}
+	events := mgr.Events.Query("sing-box", time.Time{}, time.Time{}, 0)
+	last := events[len(events)-1]
+	if last.Kind != EventTransition || last.Cause != CauseObserved || last.From != StatusRunning || last.To != StatusFailed || last.Detail != "exit code 137" {
+		t.Fatalf("crash event = %+v", last)
+	}
+}
+
+func TestManagerRestartAfterFailure(t *testing.T) {
+	mgr, sim := newSimManager(t, "mihomo")
+	ctx := context.Background()
+	if err := mgr.Start(ctx, "mihomo"); err != nil {
+		t.Fatal(err)
+	}
+	pid := mustStatus(t, mgr, "mihomo").PID
+
+	boom := errors.New("config invalid")
+	sim.FailNext("mihomo", "restart", boom)
+	if err := mgr.Restart(ctx, "mihomo"); !errors.Is(err, boom) {
+		t.Fatalf("Restart err = %v, want %v", err, boom)
+	}
+	if snap := mustStatus(t, mgr, "mihomo"); snap.Status != StatusFailed {
+		t.Fatalf("after failed restart: %+v", snap)
+	}
+
+	if err := mgr.Restart(ctx, "mihomo"); err != nil {
+		t.Fatalf("Restart after failure: %v", err)
+	}
+	snap := mustStatus(t, mgr, "mihomo")
+	if snap.Status != StatusRunning || snap.PID == 0 || snap.PID == pid {
+		t.Fatalf("after restart: %+v (old pid %d)", snap, pid)
+	}
+
+	// 崩溃后同样可以直接启动恢复。
+	sim.Crash("mihomo", 1)
+	if snap := mustStatus(t, mgr, "mihomo"); snap.Status != StatusFailed {
+		t.Fatalf("after crash: %+v", snap)
+	}
+	if err := mgr.Start(ctx, "mihomo"); err != nil {
+		t.Fatalf("Start after crash: %v", err)
+	}
+	if snap := mustStatus(t, mgr, "mihomo"); snap.Status != StatusRunning {
+		t.Fatalf("after start: %+v", snap)
+	}
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// simUnit 是模拟器中单个服务的状态。
+type simUnit struct {
+	status   Status
+	pid      int
+	since    time.Time
+	enabled  bool
+	restarts int
+	lastExit *ExitInfo
+}
+
+// Simulator 是内存中的服务后端，按 systemd 的语义模拟状态迁移，可注入失败，用于无 systemd 的开发环境与测试。
+type Simulator struct {
+	mu       sync.Mutex
+	units    map[string]*simUnit
+	failures map[string]simFailure
+	nextPID  int
+}
+
+type simFailure struct {
+	err  error
+	once bool
+}
+
+// NewSimulator 创建模拟器，所有服务初始为 stopped。
+func NewSimulator() *Simulator {
+	return &Simulator{
+		units:    make(map[string]*simUnit),
+		failures: make(map[string]simFailure),
+		nextPID:  1000,
+	}
+}
+
+func (s *Simulator) Name() string { return "simulator" }
+
+// Fail 使服务的某个动作（start/stop/restart/status/enable）持续返回 err，err 为 nil 时取消。
+func (s *Simulator) Fail(name, action string, err error) {
+	s.setFailure(name, action, err, false)
+}
+
+// FailNext 使服务的某个动作仅下一次返回 err。
+func (s *Simulator) FailNext(name, action string, err error) {
+	s.setFailure(name, action, err, true)
+}
+
+func (s *Simulator) setFailure(name, action string, err error, once bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := failureKey(name, action)
+	if err == nil {
+		delete(s.failures, key)
+		return
+	}
+	s.failures[key] = simFailure{err: err, once: once}
+}
+
+// Crash 模拟进程意外退出，服务进入 failed 状态。
+func (s *Simulator) Crash(name string, code int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unit := s.unitLocked(name)
+	if unit.status != StatusRunning {
+		return
+	}
+	unit.status = StatusFailed
+	unit.pid = 0
+	unit.lastExit = &ExitInfo{Code: code, Time: time.Now()}
+}
+
+func (s *Simulator) Start(ctx context.Context, spec ServiceSpec) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unit := s.unitLocked(spec.Name)
+	if err := s.injectedLocked(spec.Name, "start"); err != nil {
+		unit.status = StatusFailed
+		unit.lastExit = &ExitInfo{Code: 1, Error: err.Error(), Time: time.Now()}
+		return err
+	}
+	if unit.status == StatusRunning {
+		return fmt.Errorf("%s 已在运行 (PID %d)", spec.Name, unit.pid)
+	}
+	s.runLocked(unit)
+	return nil
+}
+
+func (s *Simulator) Stop(ctx context.Context, spec ServiceSpec) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unit := s.unitLocked(spec.Name)
+	if err := s.injectedLocked(spec.Name, "stop"); err != nil {
+		return err
+	}
+	s.stopLocked(unit)
+	return nil
+}
+
+func (s *Simulator) Restart(ctx context.Context, spec ServiceSpec) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unit := s.unitLocked(spec.Name)
+	if err := s.injectedLocked(spec.Name, "restart"); err != nil {
+		s.stopLocked(unit)
+		unit.status = StatusFailed
+		unit.lastExit = &ExitInfo{Code: 1, Error: err.Error(), Time: time.Now()}
+		return err
+	}
+	s.stopLocked(unit)
+	s.runLocked(unit)
+	return nil
+}
+
+func (s *Simulator) Status(ctx context.Context, spec ServiceSpec) (Snapshot, error) {
+	if err := ctx.Err(); err != nil {
+		return Snapshot{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.injectedLocked(spec.Name, "status"); err != nil {
+		return Snapshot{}, err
+	}
+	unit := s.unitLocked(spec.Name)
+	enabled := unit.enabled
+	snap := Snapshot{
+		Name:        spec.Name,
+		Unit:        spec.Unit,
+		Status:      unit.status,
+		LastUpdated: time.Now(),
+		Enabled:     &enabled,
+		Restarts:    unit.restarts,
+	}
+	if unit.status == StatusRunning {
+		since := unit.since
+		snap.PID = unit.pid
+		snap.ActiveSince = &since
+		snap.UptimeSeconds = int64(time.Since(since).Seconds())
+	}
+	if unit.lastExit != nil {
+		exit := *unit.lastExit
+		snap.LastExit = &exit
+	}
+	return snap, nil
+}
+
+func (s *Simulator) Enable(ctx context.Context, spec ServiceSpec, enabled bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.injectedLocked(spec.Name, "enable"); err != nil {
+		return err
+	}
+	s.unitLocked(spec.Name).enabled = enabled
+	return nil
+}
+
+func (s *Simulator) unitLocked(name string) *simUnit {
+	key := strings.ToLower(name)
+	unit, ok := s.units[key]
+	if !ok {
+		unit = &simUnit{status: StatusStopped}
+		s.units[key] = unit
+	}
+	return unit
+}
+
+func (s *Simulator) injectedLocked(name, action string) error {
+	key := failureKey(name, action)
+	failure, ok := s.failures[key]
+	if !ok {
+		return nil
+	}
+	if failure.once {
+		delete(s.failures, key)
+	}
+	return failure.err
+}
+
+func (s *Simulator) runLocked(unit *simUnit) {
+	s.nextPID++
+	unit.status = StatusRunning
+	unit.pid = s.nextPID
+	unit.since = time.Now()
+}
+
+func (s *Simulator) stopLocked(unit *simUnit) {
+	if unit.status != StatusRunning {
+		if unit.status == StatusFailed {
+			unit.status = StatusStopped
+		}
+		return
+	}
+	unit.status = StatusStopped
+	unit.pid = 0
+	unit.lastExit = &ExitInfo{Signal: "terminated", Time: time.Now()}
+}
+
+func failureKey(name, action string) string {
+	return strings.ToLower(name) + "/" + strings.ToLower(action)
+}
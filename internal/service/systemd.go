@@ -0,0 +1,196 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// systemdProperties 是 Status 通过 systemctl show 查询的 unit 属性。
+var systemdProperties = []string{
+	"LoadState",
+	"ActiveState",
+	"SubState",
+	"Result",
+	"MainPID",
+	"ActiveEnterTimestamp",
+	"NRestarts",
+	"MemoryCurrent",
+	"CPUUsageNSec",
+}
+
+// SystemdBackend 通过 systemctl 控制 unit。
+type SystemdBackend struct{}
+
+func (SystemdBackend) Name() string { return "systemd" }
+
+func (SystemdBackend) Start(ctx context.Context, spec ServiceSpec) error {
+	return execSystemctl(ctx, "start", spec.Unit)
+}
+
+func (SystemdBackend) Stop(ctx context.Context, spec ServiceSpec) error {
+	return execSystemctl(ctx, "stop", spec.Unit)
+}
+
+func (SystemdBackend) Restart(ctx context.Context, spec ServiceSpec) error {
+	return execSystemctl(ctx, "restart", spec.Unit)
+}
+
+func (SystemdBackend) Enable(ctx context.Context, spec ServiceSpec, enabled bool) error {
+	action := "disable"
+	if enabled {
+		action = "enable"
+	}
+	return execSystemctl(ctx, action, spec.Unit)
+}
+
+func (SystemdBackend) Status(ctx context.Context, spec ServiceSpec) (Snapshot, error) {
+	snap, err := systemdSnapshot(ctx, spec)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if enabled, ok := systemdEnabled(ctx, spec.Unit); ok {
+		snap.Enabled = &enabled
+	}
+	return snap, nil
+}
+
+func execSystemctl(ctx context.Context, action string, unit string) error {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, "systemctl", action, unit).CombinedOutput()
+	if err != nil {
+		msg := strings.TrimSpace(string(output))
+		if msg == "" {
+			msg = err.Error()
+		}
+		return fmt.Errorf("systemctl %s %s 失败: %s", action, unit, msg)
+	}
+	return nil
+}
+
+// systemdEnabled 解析 systemctl is-enabled 的输出，disabled 等状态会返回非零退出码，因此忽略错误。
+func systemdEnabled(ctx context.Context, unit string) (bool, bool) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	output, _ := exec.CommandContext(ctx, "systemctl", "is-enabled", unit).Output()
+	switch strings.TrimSpace(string(output)) {
+	case "enabled", "enabled-runtime", "alias":
+		return true, true
+	case "":
+		return false, false
+	default:
+		return false, true
+	}
+}
+
+// systemdSnapshot 查询 unit 属性并转换为 Snapshot。
+func systemdSnapshot(ctx context.Context, spec ServiceSpec) (Snapshot, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "systemctl", "show", spec.Unit, "-p", strings.Join(systemdProperties, ","))
+	output, err := cmd.Output()
+	if err != nil {
+		return Snapshot{}, err
+	}
+	props := parseSystemdProperties(output)
+	snap := Snapshot{
+		Name:        spec.Name,
+		Unit:        spec.Unit,
+		Status:      systemdStatus(props),
+		LastUpdated: time.Now(),
+		SubState:    props["SubState"],
+		Result:      props["Result"],
+	}
+	if snap.Status == StatusRunning {
+		if pid, err := strconv.Atoi(props["MainPID"]); err == nil && pid > 0 {
+			snap.PID = pid
+		}
+		if since, ok := parseSystemdTimestamp(props["ActiveEnterTimestamp"]); ok {
+			snap.ActiveSince = &since
+			snap.UptimeSeconds = int64(time.Since(since).Seconds())
+		}
+		snap.MemoryBytes = parseSystemdUint(props["MemoryCurrent"])
+		snap.CPUUsageNSec = parseSystemdUint(props["CPUUsageNSec"])
+	}
+	if n, err := strconv.Atoi(props["NRestarts"]); err == nil {
+		snap.Restarts = n
+	}
+	return snap, nil
+}
+
+func parseSystemdProperties(output []byte) map[string]string {
+	props := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		props[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return props
+}
+
+// systemdStatus 将 ActiveState/SubState 映射为 Status，failed 单独区分。
+// 崩溃循环中的 unit 停留在 activating/auto-restart，视为 failed，只有 activating/start* 才是 starting。
+func systemdStatus(props map[string]string) Status {
+	if props["LoadState"] == "not-found" {
+		return StatusStopped
+	}
+	switch props["ActiveState"] {
+	case "active", "reloading":
+		return StatusRunning
+	case "activating":
+		sub := props["SubState"]
+		switch {
+		case strings.HasPrefix(sub, "auto-restart"):
+			return StatusFailed
+		case strings.HasPrefix(sub, "start"):
+			return StatusStarting
+		default:
+			return StatusStopped
+		}
+	case "failed":
+		return StatusFailed
+	case "":
+		return StatusUnknown
+	default:
+		return StatusStopped
+	}
+}
+
+// parseSystemdTimestamp 兼容 "Thu 2024-01-04 10:00:00 CST" 与 --timestamp=unix 的 "@1704333600" 两种格式。
+func parseSystemdTimestamp(raw string) (time.Time, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || raw == "n/a" {
+		return time.Time{}, false
+	}
+	if strings.HasPrefix(raw, "@") {
+		sec, err := strconv.ParseInt(raw[1:], 10, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return time.Unix(sec, 0), true
+	}
+	for _, layout := range []string{"Mon 2006-01-02 15:04:05 MST", "Mon 2006-01-02 15:04:05 -0700"} {
+		if ts, err := time.ParseInLocation(layout, raw, time.Local); err == nil {
+			return ts, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseSystemdUint 解析数值属性，"[not set]" 与 UINT64_MAX 视为未知。
+func parseSystemdUint(raw string) uint64 {
+	val, err := strconv.ParseUint(strings.TrimSpace(raw), 10, 64)
+	if err != nil || val == ^uint64(0) {
+		return 0
+	}
+	return val
+}
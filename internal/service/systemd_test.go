@@ -0,0 +1,30 @@
+package service
+
+import "testing"
+
+func TestSystemdStatus(t *testing.T) {
+	cases := []struct {
+		active, sub, load string
+		want              Status
+	}{
+		{"active", "running", "loaded", StatusRunning},
+		{"reloading", "reload", "loaded", StatusRunning},
+		{"activating", "start", "loaded", StatusStarting},
+		{"activating", "start-pre", "loaded", StatusStarting},
+		{"activating", "start-post", "loaded", StatusStarting},
+		{"activating", "auto-restart", "loaded", StatusFailed},
+		{"activating", "auto-restart-queued", "loaded", StatusFailed},
+		{"activating", "condition", "loaded", StatusStopped},
+		{"failed", "failed", "loaded", StatusFailed},
+		{"inactive", "dead", "loaded", StatusStopped},
+		{"deactivating", "stop-sigterm", "loaded", StatusStopped},
+		{"inactive", "dead", "not-found", StatusStopped},
+		{"", "", "", StatusUnknown},
+	}
+	for _, tc := range cases {
+		props := map[string]string{"ActiveState": tc.active, "SubState": tc.sub, "LoadState": tc.load}
+		if got := systemdStatus(props); got != tc.want {
+			t.Errorf("ActiveState=%s SubState=%s LoadState=%s: got %s, want %s", tc.active, tc.sub, tc.load, got, tc.want)
+		}
+	}
+}